@@ -0,0 +1,336 @@
+package pshdlApi
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// ArchiveFormat is the file format of a workspace archive
+type ArchiveFormat int
+
+// Supported archive formats
+const (
+	ArchiveZip ArchiveFormat = iota
+	ArchiveTarGz
+)
+
+func (f ArchiveFormat) String() string {
+	switch f {
+	case ArchiveZip:
+		return "zip"
+	case ArchiveTarGz:
+		return "tar.gz"
+	}
+	return fmt.Sprintf("ArchiveFormat(%d)", int(f))
+}
+
+// ParseArchiveFormat returns the format for a name like "zip" or "tar.gz"
+// or a file name with one of these extensions.
+func ParseArchiveFormat(s string) (ArchiveFormat, error) {
+	s = strings.ToLower(s)
+	switch {
+	case s == "zip" || strings.HasSuffix(s, ".zip"):
+		return ArchiveZip, nil
+	case s == "tar.gz" || s == "tgz" || strings.HasSuffix(s, ".tar.gz") || strings.HasSuffix(s, ".tgz"):
+		return ArchiveTarGz, nil
+	}
+	return 0, fmt.Errorf("unknown archive format %q", s)
+}
+
+// ManifestName is the name of the manifest in a workspace archive
+const ManifestName = "pshdl-manifest.json"
+
+// Manifest describes the content of a workspace archive
+type Manifest struct {
+	Workspace string    `json:"workspace"`
+	Created   time.Time `json:"created"`
+	// Files are the sources with their records, module infos, problems
+	// and the records of their generated outputs
+	Files []File `json:"files"`
+}
+
+// ExportOptions select what ExportArchive writes next to the sources
+type ExportOptions struct {
+	// Generated adds the generated outputs of the sources, like src-gen
+	Generated bool
+	// Manifest adds a Manifest as ManifestName
+	Manifest bool
+}
+
+// ExportArchive writes the files of the workspace as an archive in format to w
+func (h *WorkspaceHandle) ExportArchive(ctx context.Context, w io.Writer, format ArchiveFormat, opts ExportOptions) error {
+	wp, _, err := h.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
+
+	aw, err := newArchiveWriter(w, format)
+	if err != nil {
+		return err
+	}
+
+	if opts.Manifest {
+		m, err := json.MarshalIndent(Manifest{Workspace: wp.ID, Created: time.Now().UTC(), Files: wp.Files}, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := aw.add(ManifestName, m, time.Now()); err != nil {
+			return err
+		}
+	}
+
+	add := func(rec Record) error {
+		name, ok := recordName(rec)
+		if !ok {
+			return fmt.Errorf("%w: %q", ErrUnsafePath, rec.RelPath)
+		}
+
+		content, err := h.readRecord(ctx, rec)
+		if err != nil {
+			return fmt.Errorf("reading %s failed: %w", name, err)
+		}
+		return aw.add(name, content, rec.ModTime())
+	}
+
+	for _, f := range wp.Files {
+		if err := add(f.Record); err != nil {
+			return err
+		}
+
+		if !opts.Generated {
+			continue
+		}
+		for _, rec := range f.Info.Files {
+			if err := add(rec); err != nil {
+				return err
+			}
+		}
+	}
+
+	return aw.Close()
+}
+
+// Limits of ImportArchive, for a single file and for all files of an archive
+// together. A zip archive itself must not be larger than maxArchiveSize either.
+var (
+	maxArchiveFileSize int64 = 8 << 20
+	maxArchiveSize     int64 = 64 << 20
+)
+
+// ImportArchive uploads the sources from an archive in format to the
+// workspace and validates it. Generated outputs and the manifest are left out.
+// Archives with files larger than 8 MiB or more than 64 MiB in total are
+// rejected with ErrArchiveTooLarge, archives with the same file twice or
+// paths that aren't clean with an error as well.
+func (h *WorkspaceHandle) ImportArchive(ctx context.Context, r io.Reader, format ArchiveFormat) (*ValidationResult, error) {
+	files, err := readArchive(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	generated := map[string]bool{}
+	if m, ok := files[ManifestName]; ok {
+		var manifest Manifest
+		if err := json.Unmarshal(m, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid manifest: %w", err)
+		}
+		for _, f := range manifest.Files {
+			for _, rec := range f.Info.Files {
+				if name, ok := recordName(rec); ok {
+					generated[name] = true
+				}
+			}
+		}
+		delete(files, ManifestName)
+	}
+
+	uploads := make(map[string]io.Reader, len(files))
+	for name, content := range files {
+		if generated[name] || isGenerated(name) {
+			continue
+		}
+		uploads[name] = bytes.NewReader(content)
+	}
+
+	if err := h.UploadFiles(ctx, uploads); err != nil {
+		return nil, err
+	}
+
+	return h.Validate(ctx)
+}
+
+// ImportArchive creates a new workspace from an archive, see WorkspaceHandle.ImportArchive
+func (c *Client) ImportArchive(ctx context.Context, r io.Reader, format ArchiveFormat, opts CreateOptions) (*WorkspaceHandle, *ValidationResult, error) {
+	h, _, err := c.CreateWorkspace(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := h.ImportArchive(ctx, r, format)
+	if err != nil {
+		return h, nil, err
+	}
+
+	return h, res, nil
+}
+
+type archiveWriter interface {
+	add(name string, content []byte, mtime time.Time) error
+	Close() error
+}
+
+func newArchiveWriter(w io.Writer, format ArchiveFormat) (archiveWriter, error) {
+	switch format {
+	case ArchiveZip:
+		return zipWriter{zip.NewWriter(w)}, nil
+	case ArchiveTarGz:
+		gz := gzip.NewWriter(w)
+		return &tarGzWriter{gz: gz, tw: tar.NewWriter(gz)}, nil
+	}
+	return nil, fmt.Errorf("unknown archive format %v", format)
+}
+
+type zipWriter struct {
+	*zip.Writer
+}
+
+func (z zipWriter) add(name string, content []byte, mtime time.Time) error {
+	f, err := z.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: mtime})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	return err
+}
+
+type tarGzWriter struct {
+	gz *gzip.Writer
+	tw *tar.Writer
+}
+
+func (t *tarGzWriter) add(name string, content []byte, mtime time.Time) error {
+	err := t.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  mtime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.tw.Write(content)
+	return err
+}
+
+func (t *tarGzWriter) Close() error {
+	if err := t.tw.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
+
+// readArchive returns the regular files of an archive by their names.
+// Names may start with "./" but must be clean otherwise and unique.
+func readArchive(r io.Reader, format ArchiveFormat) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+	var total int64
+
+	add := func(name string, rc io.Reader) error {
+		clean := strings.TrimPrefix(name, "./")
+		if !fs.ValidPath(clean) || clean == "." {
+			return fmt.Errorf("%w: %q", ErrUnsafePath, name)
+		}
+		if _, ok := files[clean]; ok {
+			return fmt.Errorf("duplicate file %q in archive", clean)
+		}
+
+		content, err := ioutil.ReadAll(io.LimitReader(rc, maxArchiveFileSize+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(content)) > maxArchiveFileSize {
+			return fmt.Errorf("%w: %s is larger than %d bytes", ErrArchiveTooLarge, clean, maxArchiveFileSize)
+		}
+		total += int64(len(content))
+		if total > maxArchiveSize {
+			return fmt.Errorf("%w: files are larger than %d bytes", ErrArchiveTooLarge, maxArchiveSize)
+		}
+
+		files[clean] = content
+		return nil
+	}
+
+	switch format {
+	case ArchiveZip:
+		// zip needs random access
+		data, err := ioutil.ReadAll(io.LimitReader(r, maxArchiveSize+1))
+		if err != nil {
+			return nil, err
+		}
+		if int64(len(data)) > maxArchiveSize {
+			return nil, fmt.Errorf("%w: larger than %d bytes", ErrArchiveTooLarge, maxArchiveSize)
+		}
+
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, f := range zr.File {
+			if !f.Mode().IsRegular() {
+				continue
+			}
+
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			err = add(f.Name, rc)
+			rc.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			if hdr.Typeflag != tar.TypeReg {
+				continue
+			}
+			if err := add(hdr.Name, tr); err != nil {
+				return nil, err
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown archive format %v", format)
+	}
+
+	return files, nil
+}
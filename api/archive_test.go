@@ -0,0 +1,203 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestArchive(t *testing.T) {
+	Convey("Given a workspace with a source and generated code", t, func() {
+		setup()
+
+		contents := map[string]string{
+			"alu.pshdl":            "module alu {}",
+			"src-gen/psex/c/alu.c": "int main() {}",
+		}
+		mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, contents[r.URL.Path[len("/api/v0.1/workspace/1234/"):]])
+		})
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"1234","files":[{
+				"record":{"relPath":"alu.pshdl","fileURI":"/api/v0.1/workspace/1234/alu.pshdl"},
+				"moduleInfos":[{"name":"alu"}],
+				"info":{
+					"files":[{"relPath":"src-gen/psex/c/alu.c","fileURI":"/api/v0.1/workspace/1234/src-gen/psex/c/alu.c"}],
+					"problems":[{"errorCode":"W1","severity":"WARNING"}]
+				}
+			}]}`)
+		})
+
+		// the target of imports
+		uploaded := map[string]string{}
+		validated := false
+		mux.HandleFunc("/api/v0.1/workspace/CAFE", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				fmt.Fprint(w, `{"id":"CAFE"}`)
+				return
+			}
+			mr, err := r.MultipartReader()
+			So(err, ShouldBeNil)
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				So(err, ShouldBeNil)
+				c, _ := ioutil.ReadAll(part)
+				uploaded[partFileName(part)] = string(c)
+			}
+		})
+		mux.HandleFunc("/api/v0.1/compiler/CAFE/validate", func(w http.ResponseWriter, r *http.Request) {
+			validated = true
+			fmt.Fprint(w, `{"id":"CAFE","validated":true}`)
+		})
+		mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"CAFE"}`)
+		})
+
+		ws := client.OpenWorkspace("1234")
+
+		for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarGz} {
+			format := format
+
+			Convey("ExportArchive() as "+format.String(), func() {
+				var buf bytes.Buffer
+
+				Convey("should write only the sources by default", func() {
+					So(ws.ExportArchive(context.Background(), &buf, format, ExportOptions{}), ShouldBeNil)
+
+					files, err := readArchive(&buf, format)
+					So(err, ShouldBeNil)
+					So(files, ShouldHaveLength, 1)
+					So(string(files["alu.pshdl"]), ShouldEqual, "module alu {}")
+				})
+
+				Convey("should add generated code and the manifest", func() {
+					So(ws.ExportArchive(context.Background(), &buf, format, ExportOptions{Generated: true, Manifest: true}), ShouldBeNil)
+
+					files, err := readArchive(bytes.NewReader(buf.Bytes()), format)
+					So(err, ShouldBeNil)
+
+					var names []string
+					for n := range files {
+						names = append(names, n)
+					}
+					sort.Strings(names)
+					So(names, ShouldResemble, []string{"alu.pshdl", ManifestName, "src-gen/psex/c/alu.c"})
+
+					var m Manifest
+					So(json.Unmarshal(files[ManifestName], &m), ShouldBeNil)
+					So(m.Workspace, ShouldEqual, "1234")
+					So(m.Files, ShouldHaveLength, 1)
+					So(m.Files[0].ModuleInfos[0].Name, ShouldEqual, "alu")
+					So(m.Files[0].Info.Problems[0].ErrorCode, ShouldEqual, "W1")
+
+					Convey("and ImportArchive() should upload the sources into a new workspace", func() {
+						h, res, err := client.ImportArchive(context.Background(), &buf, format, CreateOptions{})
+						So(err, ShouldBeNil)
+						So(h.ID(), ShouldEqual, "CAFE")
+						So(res.Workspace.Validated, ShouldBeTrue)
+						So(validated, ShouldBeTrue)
+						So(uploaded, ShouldResemble, map[string]string{"alu.pshdl": "module alu {}"})
+					})
+				})
+			})
+		}
+
+		Convey("ImportArchive() should reject paths outside of the workspace", func() {
+			var buf bytes.Buffer
+			aw, err := newArchiveWriter(&buf, ArchiveTarGz)
+			So(err, ShouldBeNil)
+			So(aw.add("../evil.pshdl", []byte("x"), time.Time{}), ShouldBeNil)
+			So(aw.Close(), ShouldBeNil)
+
+			_, err = client.OpenWorkspace("CAFE").ImportArchive(context.Background(), &buf, ArchiveTarGz)
+			So(errors.Is(err, ErrUnsafePath), ShouldBeTrue)
+			So(uploaded, ShouldBeEmpty)
+		})
+
+		archive := func(format ArchiveFormat, files ...string) *bytes.Buffer {
+			var buf bytes.Buffer
+			aw, err := newArchiveWriter(&buf, format)
+			So(err, ShouldBeNil)
+			for i := 0; i < len(files); i += 2 {
+				So(aw.add(files[i], []byte(files[i+1]), time.Time{}), ShouldBeNil)
+			}
+			So(aw.Close(), ShouldBeNil)
+			return &buf
+		}
+
+		Convey("ImportArchive() should reject paths that aren't clean", func() {
+			buf := archive(ArchiveTarGz, "lib/../alu.pshdl", "x")
+			_, err := client.OpenWorkspace("CAFE").ImportArchive(context.Background(), buf, ArchiveTarGz)
+			So(errors.Is(err, ErrUnsafePath), ShouldBeTrue)
+			So(uploaded, ShouldBeEmpty)
+		})
+
+		Convey("ImportArchive() should reject files that appear twice", func() {
+			buf := archive(ArchiveTarGz, "alu.pshdl", "a", "./alu.pshdl", "b")
+			_, err := client.OpenWorkspace("CAFE").ImportArchive(context.Background(), buf, ArchiveTarGz)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "duplicate")
+			So(uploaded, ShouldBeEmpty)
+		})
+
+		Convey("With small import limits", func() {
+			fileSize, size := maxArchiveFileSize, maxArchiveSize
+			maxArchiveFileSize, maxArchiveSize = 8, 200
+			Reset(func() { maxArchiveFileSize, maxArchiveSize = fileSize, size })
+
+			for _, format := range []ArchiveFormat{ArchiveZip, ArchiveTarGz} {
+				format := format
+
+				Convey("ImportArchive() of "+format.String()+" should reject a large file", func() {
+					buf := archive(format, "alu.pshdl", "module alu {}")
+					_, err := client.OpenWorkspace("CAFE").ImportArchive(context.Background(), buf, format)
+					So(errors.Is(err, ErrArchiveTooLarge), ShouldBeTrue)
+					So(uploaded, ShouldBeEmpty)
+				})
+			}
+
+			Convey("ImportArchive() should reject too many files", func() {
+				maxArchiveSize = 20
+				buf := archive(ArchiveTarGz, "a.pshdl", "12345678", "b.pshdl", "12345678", "c.pshdl", "12345678")
+				_, err := client.OpenWorkspace("CAFE").ImportArchive(context.Background(), buf, ArchiveTarGz)
+				So(errors.Is(err, ErrArchiveTooLarge), ShouldBeTrue)
+				So(uploaded, ShouldBeEmpty)
+			})
+
+			Convey("ImportArchive() should reject a large zip archive", func() {
+				buf := archive(ArchiveZip, "a.pshdl", "1", "b.pshdl", "2", "c.pshdl", "3", "d.pshdl", "4")
+				So(buf.Len(), ShouldBeGreaterThan, 200)
+				_, err := client.OpenWorkspace("CAFE").ImportArchive(context.Background(), buf, ArchiveZip)
+				So(errors.Is(err, ErrArchiveTooLarge), ShouldBeTrue)
+			})
+		})
+
+		Convey("ParseArchiveFormat() should know file names", func() {
+			f, err := ParseArchiveFormat("review.TGZ")
+			So(err, ShouldBeNil)
+			So(f, ShouldEqual, ArchiveTarGz)
+
+			f, err = ParseArchiveFormat("zip")
+			So(err, ShouldBeNil)
+			So(f, ShouldEqual, ArchiveZip)
+
+			_, err = ParseArchiveFormat("rar")
+			So(err, ShouldNotBeNil)
+		})
+
+		Reset(teardown)
+	})
+}
@@ -0,0 +1,270 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// CacheEntry is a response body stored in a Cache
+type CacheEntry struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Body         []byte `json:"body"`
+}
+
+// Cache stores API responses. Keys are request URLs, for downloaded
+// records the key also contains Record.Hash.
+// Implementations have to be safe for concurrent use.
+type Cache interface {
+	Get(key string) (*CacheEntry, bool)
+	Set(key string, e *CacheEntry) error
+	Delete(key string) error
+}
+
+// WithCache enables response caching using cache.
+// GET requests are sent with If-None-Match and If-Modified-Since when the
+// cache has a matching entry and records with an unchanged hash aren't
+// downloaded again.
+func WithCache(cache Cache) Option {
+	return func(c *Client) error {
+		c.cache = cache
+		return nil
+	}
+}
+
+// DefaultMemoryCacheSize is the size limit of NewMemoryCache in bytes
+const DefaultMemoryCacheSize = 32 << 20
+
+// MemoryCache is a Cache that keeps its entries in memory. When they
+// exceed its size limit, the least recently used entries are evicted.
+type MemoryCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	lru      *list.List // of *memoryItem, most recently used first
+	entries  map[string]*list.Element
+}
+
+type memoryItem struct {
+	key  string
+	e    *CacheEntry
+	size int64
+}
+
+// NewMemoryCache returns an empty MemoryCache limited to DefaultMemoryCacheSize
+func NewMemoryCache() *MemoryCache {
+	return NewMemoryCacheSize(DefaultMemoryCacheSize)
+}
+
+// NewMemoryCacheSize returns an empty MemoryCache that keeps at most maxBytes
+// of keys and entries. Entries larger than that aren't stored.
+func NewMemoryCacheSize(maxBytes int64) *MemoryCache {
+	return &MemoryCache{
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+// Get implements Cache
+func (m *MemoryCache) Get(key string) (*CacheEntry, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.entries[key]
+	if !ok {
+		return nil, false
+	}
+	m.lru.MoveToFront(el)
+	return el.Value.(*memoryItem).e, true
+}
+
+// Set implements Cache
+func (m *MemoryCache) Set(key string, e *CacheEntry) error {
+	item := &memoryItem{
+		key:  key,
+		e:    e,
+		size: int64(len(key) + len(e.ETag) + len(e.LastModified) + len(e.Body)),
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.remove(key)
+	if item.size > m.maxBytes {
+		return nil
+	}
+
+	m.entries[key] = m.lru.PushFront(item)
+	m.size += item.size
+	for m.size > m.maxBytes {
+		m.remove(m.lru.Back().Value.(*memoryItem).key)
+	}
+	return nil
+}
+
+// Delete implements Cache
+func (m *MemoryCache) Delete(key string) error {
+	m.mu.Lock()
+	m.remove(key)
+	m.mu.Unlock()
+	return nil
+}
+
+// remove deletes the entry for key, m.mu must be held
+func (m *MemoryCache) remove(key string) {
+	el, ok := m.entries[key]
+	if !ok {
+		return
+	}
+	m.lru.Remove(el)
+	delete(m.entries, key)
+	m.size -= el.Value.(*memoryItem).size
+}
+
+// DiskCache is a Cache that stores each entry as a file in a directory,
+// so it survives restarts of the program.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a DiskCache storing its entries in dir.
+// dir is created if it doesn't exist.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (d *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
+
+// Get implements Cache. Unreadable entries count as missing.
+func (d *DiskCache) Get(key string) (*CacheEntry, bool) {
+	data, err := ioutil.ReadFile(d.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	e := new(CacheEntry)
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, false
+	}
+	return e, true
+}
+
+// Set implements Cache
+func (d *DiskCache) Set(key string, e *CacheEntry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(d.dir, "tmp-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+
+	return os.Rename(f.Name(), d.path(key))
+}
+
+// Delete implements Cache
+func (d *DiskCache) Delete(key string) error {
+	err := os.Remove(d.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// recordCacheKey is the cache key for the content of rec
+func recordCacheKey(rec Record) string {
+	return rec.FileURI + "#" + rec.Hash
+}
+
+type skipURLCacheKey struct{}
+
+// skipURLCache returns a copy of ctx for requests whose response is cached
+// under another key, like records under recordCacheKey, so send doesn't
+// store it a second time under its URL
+func skipURLCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, skipURLCacheKey{}, true)
+}
+
+// send sends req through the cache of the client, if there is one, and roundTrip
+func (c *Client) send(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if skip, _ := ctx.Value(skipURLCacheKey{}).(bool); c.cache == nil || req.Method != "GET" || skip {
+		return c.roundTrip(ctx, req)
+	}
+
+	key := req.URL.String()
+	cached, ok := c.cache.Get(key)
+	if ok {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.roundTrip(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		c.logger.Debug("cache hit", "path", req.URL.Path)
+		resp.Body.Close()
+
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+		resp.Header.Set("Content-Length", strconv.Itoa(len(cached.Body)))
+
+	case resp.StatusCode == http.StatusOK:
+		etag, lastMod := resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+		if etag == "" && lastMod == "" {
+			break
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		err = c.cache.Set(key, &CacheEntry{ETag: etag, LastModified: lastMod, Body: body})
+		if err != nil {
+			c.logger.Warn("could not cache response", "path", req.URL.Path, "err", err)
+		}
+	}
+
+	return resp, nil
+}
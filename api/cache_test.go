@@ -0,0 +1,212 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCaches(t *testing.T) {
+	disk, err := NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cache := range map[string]Cache{
+		"MemoryCache": NewMemoryCache(),
+		"DiskCache":   disk,
+	} {
+		Convey("Given a "+name, t, func() {
+			Convey("Get() of a missing key should miss", func() {
+				_, ok := cache.Get("nope")
+				So(ok, ShouldBeFalse)
+			})
+
+			Convey("Set() should store the entry", func() {
+				So(cache.Set("key", &CacheEntry{ETag: `"v1"`, Body: []byte("hello")}), ShouldBeNil)
+
+				e, ok := cache.Get("key")
+				So(ok, ShouldBeTrue)
+				So(e.ETag, ShouldEqual, `"v1"`)
+				So(string(e.Body), ShouldEqual, "hello")
+
+				Convey("and Delete() should remove it", func() {
+					So(cache.Delete("key"), ShouldBeNil)
+					_, ok := cache.Get("key")
+					So(ok, ShouldBeFalse)
+				})
+			})
+		})
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	Convey("Given a MemoryCache with room for two entries", t, func() {
+		m := NewMemoryCacheSize(2 * int64(len("k1")+len("12345")))
+		entry := func() *CacheEntry { return &CacheEntry{Body: []byte("12345")} }
+
+		So(m.Set("k1", entry()), ShouldBeNil)
+		So(m.Set("k2", entry()), ShouldBeNil)
+
+		Convey("Set() should evict the least recently used entry", func() {
+			_, ok := m.Get("k1")
+			So(ok, ShouldBeTrue)
+
+			So(m.Set("k3", entry()), ShouldBeNil)
+			_, ok = m.Get("k2")
+			So(ok, ShouldBeFalse)
+			_, ok = m.Get("k1")
+			So(ok, ShouldBeTrue)
+			_, ok = m.Get("k3")
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("Set() should not store entries larger than the limit", func() {
+			So(m.Set("big", &CacheEntry{Body: make([]byte, 100)}), ShouldBeNil)
+			_, ok := m.Get("big")
+			So(ok, ShouldBeFalse)
+			_, ok = m.Get("k1")
+			So(ok, ShouldBeTrue)
+		})
+
+		Convey("replacing an entry should not count it twice", func() {
+			for i := 0; i < 10; i++ {
+				So(m.Set("k1", entry()), ShouldBeNil)
+			}
+			_, ok := m.Get("k2")
+			So(ok, ShouldBeTrue)
+		})
+	})
+}
+
+// countingCache counts the keys set in the wrapped Cache
+type countingCache struct {
+	Cache
+	mu   sync.Mutex
+	keys []string
+}
+
+func (c *countingCache) Set(key string, e *CacheEntry) error {
+	c.mu.Lock()
+	c.keys = append(c.keys, key)
+	c.mu.Unlock()
+	return c.Cache.Set(key, e)
+}
+
+func TestClientCache(t *testing.T) {
+	Convey("Given a test server and a client with a cache", t, func() {
+		setup()
+
+		var err error
+		client, err = NewClient(WithBaseURL(server.URL+"/api/v0.1/"), WithWorkspaceID("1234"), WithCache(NewMemoryCache()))
+		So(err, ShouldBeNil)
+
+		Convey("GetInfo() should revalidate with If-None-Match", func() {
+			var full, notModified int32
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-None-Match") == `"v1"` {
+					atomic.AddInt32(&notModified, 1)
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				atomic.AddInt32(&full, 1)
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, `{"id":"1234","files":[{"record":{"relPath":"a.pshdl"}}]}`)
+			})
+
+			for i := 0; i < 3; i++ {
+				wp, _, err := client.Workspace.GetInfo(context.Background())
+				So(err, ShouldBeNil)
+				So(wp.ID, ShouldEqual, "1234")
+				So(wp.Files, ShouldHaveLength, 1)
+			}
+
+			So(full, ShouldEqual, 1)
+			So(notModified, ShouldEqual, 2)
+		})
+
+		Convey("GetInfo() should revalidate with If-Modified-Since", func() {
+			const lastMod = "Mon, 02 Jan 2006 15:04:05 GMT"
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("If-Modified-Since") == lastMod {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Header().Set("Last-Modified", lastMod)
+				fmt.Fprint(w, `{"id":"1234"}`)
+			})
+
+			_, resp, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+
+			wp, resp, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(wp.ID, ShouldEqual, "1234")
+		})
+
+		Convey("DownloadRecord() should skip records with an unchanged hash", func() {
+			var (
+				hits    int32
+				content atomic.Value
+			)
+			content.Store("module a {}")
+			mux.HandleFunc("/api/v0.1/workspace/1234/a.pshdl", func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&hits, 1)
+				fmt.Fprint(w, content.Load())
+			})
+
+			hash, err := HashReader(strings.NewReader("module a {}"))
+			So(err, ShouldBeNil)
+
+			opts := DownloadOptions{Dir: t.TempDir()}
+			rec := Record{FileURI: server.URL + "/api/v0.1/workspace/1234/a.pshdl", RelPath: "a.pshdl", Hash: hash}
+
+			So(client.Workspace.DownloadRecord(context.Background(), rec, opts), ShouldBeNil)
+			So(client.Workspace.DownloadRecord(context.Background(), rec, opts), ShouldBeNil)
+			So(hits, ShouldEqual, 1)
+
+			data, err := ioutil.ReadFile(filepath.Join(opts.Dir, "a.pshdl"))
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "module a {}")
+
+			Convey("but download them again once it changed", func() {
+				content.Store("module b {}")
+				rec.Hash, err = HashReader(strings.NewReader("module b {}"))
+				So(err, ShouldBeNil)
+
+				So(client.Workspace.DownloadRecord(context.Background(), rec, opts), ShouldBeNil)
+				So(hits, ShouldEqual, 2)
+			})
+		})
+
+		Convey("DownloadRecord() should cache a record with a hash only once", func() {
+			cache := &countingCache{Cache: NewMemoryCache()}
+			client, err = NewClient(WithBaseURL(server.URL+"/api/v0.1/"), WithWorkspaceID("1234"), WithCache(cache))
+			So(err, ShouldBeNil)
+
+			mux.HandleFunc("/api/v0.1/workspace/1234/a.pshdl", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("ETag", `"v1"`)
+				fmt.Fprint(w, "module a {}")
+			})
+
+			hash, err := HashReader(strings.NewReader("module a {}"))
+			So(err, ShouldBeNil)
+			rec := Record{FileURI: server.URL + "/api/v0.1/workspace/1234/a.pshdl", RelPath: "a.pshdl", Hash: hash}
+
+			So(client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: t.TempDir()}), ShouldBeNil)
+			So(cache.keys, ShouldResemble, []string{recordCacheKey(rec)})
+		})
+
+		Reset(teardown)
+	})
+}
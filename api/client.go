@@ -2,19 +2,18 @@ package pshdlApi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
-
-	"github.com/visionmedia/go-debug"
 )
 
-var dbg = debug.Debug("pshdlApi")
-
 const (
 	libraryVersion = "0.1"
 	defaultBaseURL = "http://api6.pshdl.org/api/v0.1/"
@@ -35,53 +34,60 @@ type Client struct {
 	// User agent used when communicating with the PSHDL REST API.
 	UserAgent string
 
+	// structured logger for debug messages, silent by default
+	logger *slog.Logger
+
+	// how failed requests are retried
+	retry RetryPolicy
+
+	// wrapped around every round trip
+	middleware []Middleware
+
+	// optional response cache
+	cache Cache
+
+	// if the API takes several files in one upload, accessed atomically
+	uploadMode int32
+
 	// Services used for talking to different parts of the PSHDL REST API.
 	Workspace *WorkspaceService
 	Compiler  *CompilerService
 	Streaming *StreamingService
 }
 
-// NewClient returns a new PSHDL REST API client.  If a nil httpClient is
-// provided, http.DefaultClient will be used.
-func NewClient(httpClient *http.Client) *Client {
-	defer dbg("NewClient()")
-
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-
-	baseURL, err := url.Parse(defaultBaseURL)
+// NewClient returns a new PSHDL REST API client configured by opts.
+// Without options it talks to the public API at defaultBaseURL using
+// http.DefaultClient.
+func NewClient(opts ...Option) (*Client, error) {
+	baseURL, err := parseBaseURL(defaultBaseURL)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	c := &Client{client: httpClient, baseURL: baseURL, UserAgent: userAgent}
+	c := &Client{
+		client:    http.DefaultClient,
+		baseURL:   baseURL,
+		UserAgent: userAgent,
+		logger:    slog.New(slog.DiscardHandler),
+	}
 	c.Workspace = &WorkspaceService{client: c}
 	c.Compiler = &CompilerService{client: c}
 	c.Streaming = &StreamingService{client: c}
 
-	return c
-}
-
-// NewClientWithID returns a new PSHDL REST API client.  If a nil httpClient is
-// provided, http.DefaultClient will be used.
-func NewClientWithID(httpClient *http.Client, id string) *Client {
-	defer dbg("NewClientWithID(%s)", id)
-
-	if httpClient == nil {
-		httpClient = http.DefaultClient
-	}
-	baseURL, err := url.Parse(defaultBaseURL)
-	if err != nil {
-		panic(err)
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
 
-	c := &Client{client: httpClient, baseURL: baseURL, UserAgent: userAgent}
-	c.Workspace = &WorkspaceService{client: c, ID: id}
-	c.Compiler = &CompilerService{client: c, ID: id}
-	c.Streaming = &StreamingService{client: c, ID: id}
+	c.logger.Debug("new client", "baseURL", c.baseURL.String(), "workspace", c.Workspace.ID)
+	return c, nil
+}
 
-	return c
+// BaseURL returns the URL the client resolves API requests against
+func (c *Client) BaseURL() *url.URL {
+	u := *c.baseURL
+	return &u
 }
 
 // NewRequest creates an API request. A relative URL can be provided in urlStr,
@@ -90,8 +96,6 @@ func NewClientWithID(httpClient *http.Client, id string) *Client {
 // specified, the value pointed to by body is JSON encoded and included as the
 // request body.
 func (c *Client) NewRequest(method, urlStr string, body interface{}) (req *http.Request, err error) {
-	defer dbg("client.NewRequest[%s]: %s - %v", method, urlStr, err)
-
 	rel, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, err
@@ -119,8 +123,6 @@ func (c *Client) NewRequest(method, urlStr string, body interface{}) (req *http.
 
 // NewReaderRequest creates an API request. Uses a io.Reader and ctype instead of marshaling json.
 func (c *Client) NewReaderRequest(method, urlStr string, body io.Reader, ctype string) (req *http.Request, err error) {
-	defer dbg("client.NewReaderRequest[%s] %s - %v", method, urlStr, err)
-
 	rel, err := url.Parse(urlStr)
 	if err != nil {
 		return nil, err
@@ -144,18 +146,14 @@ func (c *Client) NewReaderRequest(method, urlStr string, body io.Reader, ctype s
 
 // Do sends an API request and returns the API response.  The API response is
 // decoded and stored in the value pointed to by v, or returned as an error if
-// an API error has occurred.
-func (c *Client) Do(req *http.Request, v interface{}) (resp *http.Response, err error) {
+// an API error has occurred.  The request is bound to ctx, cancelling it
+// aborts the request.  Failed requests are retried according to the
+// RetryPolicy of the client.
+func (c *Client) Do(ctx context.Context, req *http.Request, v interface{}) (resp *http.Response, err error) {
 	start := time.Now()
-	defer func() {
-		if err != nil {
-			dbg("client.Do(%s) Error! (%s)", req.URL.Path, err)
-			return
-		}
-		dbg("client.Do(%s) %s (%v)", req.URL.Path, resp.Status, time.Since(start))
-	}()
+	defer func() { c.logRequest(ctx, req, resp, err, time.Since(start)) }()
 
-	resp, err = c.client.Do(req)
+	resp, err = c.send(ctx, req)
 	if err != nil {
 		return nil, err
 	}
@@ -175,19 +173,14 @@ func (c *Client) Do(req *http.Request, v interface{}) (resp *http.Response, err
 }
 
 // DoPlain sends an API request and returns the API response as a slice of bytes.
-func (c *Client) DoPlain(req *http.Request) (data []byte, resp *http.Response, err error) {
+// The request is bound to ctx, cancelling it aborts the request.
+func (c *Client) DoPlain(ctx context.Context, req *http.Request) (data []byte, resp *http.Response, err error) {
 	start := time.Now()
-	defer func() {
-		if err != nil {
-			dbg("client.DoPlain(%s) Error! (%s)", req.URL.Path, err)
-			return
-		}
-		dbg("client.DoPlain(%s) %s (%v)", req.URL.Path, resp.Status, time.Since(start))
-	}()
+	defer func() { c.logRequest(ctx, req, resp, err, time.Since(start)) }()
 
 	req.Header.Set("Accept", "text/plain")
 
-	resp, err = c.client.Do(req)
+	resp, err = c.send(ctx, req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -205,36 +198,91 @@ func (c *Client) DoPlain(req *http.Request) (data []byte, resp *http.Response, e
 	return data, resp, err
 }
 
+// logRequest logs the outcome of a request made by Do or DoPlain
+func (c *Client) logRequest(ctx context.Context, req *http.Request, resp *http.Response, err error, d time.Duration) {
+	attrs := []any{
+		"method", req.Method,
+		"path", req.URL.Path,
+		"duration", d,
+	}
+	if op, ok := OperationFromContext(ctx); ok {
+		attrs = append(attrs, "op", op.String())
+	}
+	if resp != nil {
+		attrs = append(attrs, "status", resp.StatusCode)
+	}
+
+	if err != nil {
+		c.logger.Debug("request failed", append(attrs, "err", err)...)
+		return
+	}
+	c.logger.Debug("request", attrs...)
+}
+
 /*
 An ErrorResponse reports one or more errors caused by an API request.
 
-PSHDL REST API docs: http://developer.github.com/v3/#client-errors
+The kind of error can be checked with errors.Is against ErrWorkspaceNotFound,
+ErrFileNotFound and ErrRateLimited.
 */
 type ErrorResponse struct {
 	Response *http.Response // HTTP response that caused this error
-	Message  interface{}
+	Message  string         // error message sent by the API, if any
+
+	// sentinel error describing the kind of error, may be nil
+	kind error
 }
 
 func (r *ErrorResponse) Error() string {
-	return fmt.Sprintf("%v %v: %d %+v",
+	msg := fmt.Sprintf("%v %v: %d",
 		r.Response.Request.Method, r.Response.Request.URL,
-		r.Response.StatusCode, r.Message)
+		r.Response.StatusCode)
+	if r.Message != "" {
+		msg += " " + r.Message
+	}
+	return msg
+}
+
+// Unwrap returns the sentinel error matching the status of the response
+func (r *ErrorResponse) Unwrap() error {
+	return r.kind
 }
 
 // CheckResponse checks the API response for errors, and returns them if
 // present.  A response is considered an error if it has a status code outside
-// the 200 range.  API error responses are expected to have either no response
-// body, or a JSON response body that maps to ErrorResponse.  Any other
-// response body will be silently ignored.
+// the 200 range.  The error is an *ErrorResponse or, if the API rejected the
+// request because of problems in the code of the workspace, a *ValidationError.
 func CheckResponse(r *http.Response) error {
 	if c := r.StatusCode; 200 <= c && c <= 299 {
 		return nil
 	}
 
 	errorResponse := &ErrorResponse{Response: r}
+
+	switch r.StatusCode {
+	case http.StatusNotFound:
+		errorResponse.kind = notFoundKind(r.Request)
+	case http.StatusTooManyRequests:
+		errorResponse.kind = ErrRateLimited
+	}
+
 	data, err := ioutil.ReadAll(r.Body)
-	if err == nil && data != nil {
-		json.Unmarshal(data, errorResponse)
+	if err != nil || len(data) == 0 {
+		return errorResponse
+	}
+
+	var problems []Problem
+	if json.Unmarshal(data, &problems) == nil && len(problems) > 0 {
+		return &ValidationError{ErrorResponse: errorResponse, Problems: problems}
+	}
+
+	var msg struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &msg) == nil {
+		errorResponse.Message = msg.Message
+	} else {
+		errorResponse.Message = strings.TrimSpace(string(data))
 	}
 
 	return errorResponse
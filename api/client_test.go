@@ -1,9 +1,13 @@
 package pshdlApi
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -32,11 +36,11 @@ func setup() {
 	server = httptest.NewServer(mux)
 
 	// github client configured to use test server
-	client = NewClient(nil)
-	url, _ := url.Parse(server.URL + "/api/v0.1/")
-	client.baseURL = url
-	client.Workspace.ID = "1234"
-	client.Compiler.ID = "1234"
+	var err error
+	client, err = NewClient(WithBaseURL(server.URL+"/api/v0.1/"), WithWorkspaceID("1234"))
+	if err != nil {
+		panic(err)
+	}
 }
 
 // teardown closes the test HTTP server.
@@ -47,7 +51,9 @@ func teardown() {
 func TestNewClient(t *testing.T) {
 	var c *Client
 	Convey("Given a new Client", t, func() {
-		c = NewClient(nil)
+		var err error
+		c, err = NewClient()
+		So(err, ShouldBeNil)
 
 		Convey("It should have the correct BaseURL", func() {
 			So(c.baseURL.String(), ShouldEqual, defaultBaseURL)
@@ -56,6 +62,41 @@ func TestNewClient(t *testing.T) {
 		Convey("It should have the correct UserAgent", func() {
 			So(c.UserAgent, ShouldEqual, userAgent)
 		})
+
+		Convey("It should use http.DefaultClient", func() {
+			So(c.client, ShouldEqual, http.DefaultClient)
+		})
+	})
+
+	Convey("Given a new Client with options", t, func() {
+		hc := &http.Client{}
+		c, err := NewClient(
+			WithBaseURL("https://pshdl.example.com/api/v0.1"),
+			WithHTTPClient(hc),
+			WithUserAgent("tester/1.0"),
+			WithWorkspaceID("ABCD"),
+		)
+		So(err, ShouldBeNil)
+
+		Convey("It should add the trailing slash to the BaseURL", func() {
+			So(c.BaseURL().String(), ShouldEqual, "https://pshdl.example.com/api/v0.1/")
+		})
+
+		Convey("It should use the supplied settings", func() {
+			So(c.client, ShouldEqual, hc)
+			So(c.UserAgent, ShouldEqual, "tester/1.0")
+			So(c.Workspace.ID, ShouldEqual, "ABCD")
+			So(c.Compiler.ID, ShouldEqual, "ABCD")
+			So(c.Streaming.ID, ShouldEqual, "ABCD")
+		})
+	})
+
+	Convey("Given an invalid base URL", t, func() {
+		for _, u := range []string{":", "ftp://pshdl.org/", "/api/v0.1/", "http:///api/"} {
+			c, err := NewClient(WithBaseURL(u))
+			So(err, ShouldNotBeNil)
+			So(c, ShouldBeNil)
+		}
 	})
 }
 
@@ -70,7 +111,7 @@ func TestNewRequest(t *testing.T) {
 	}
 
 	Convey("Given a new Client", t, func() {
-		c = NewClient(nil)
+		c, _ = NewClient()
 
 		Convey("and a valid Request", func() {
 			inURL, outURL := "foo", defaultBaseURL+"foo"
@@ -136,7 +177,7 @@ func TestDo(t *testing.T) {
 
 			req, _ := client.NewRequest("GET", "/", nil)
 			body := new(foo)
-			client.Do(req, body)
+			client.Do(context.Background(), req, body)
 
 			So(body, ShouldResemble, &foo{"a"})
 		})
@@ -148,11 +189,51 @@ func TestDo(t *testing.T) {
 			})
 
 			req, _ := client.NewRequest("GET", "/", nil)
-			_, err := client.Do(req, nil)
+			_, err := client.Do(context.Background(), req, nil)
 			So(err, ShouldNotBeNil)
 
 		})
 
+		Convey("A logger should get structured request messages", func() {
+
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"id":"1234"}`)
+			})
+
+			var buf bytes.Buffer
+			client.logger = slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+
+			var entry struct {
+				Msg    string
+				Op     string
+				Path   string
+				Status int
+			}
+			So(json.Unmarshal(buf.Bytes(), &entry), ShouldBeNil)
+			So(entry.Msg, ShouldEqual, "request")
+			So(entry.Op, ShouldEqual, "Workspace.GetInfo")
+			So(entry.Path, ShouldEqual, "/api/v0.1/workspace/1234")
+			So(entry.Status, ShouldEqual, http.StatusOK)
+		})
+
+		Convey("A canceled context should abort the request", func() {
+
+			mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{}`)
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			req, _ := client.NewRequest("GET", "/", nil)
+			_, err := client.Do(ctx, req, nil)
+			So(err, ShouldNotBeNil)
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		})
+
 		Convey("A plain request should get response", func() {
 
 			want := `/api/v0.1/servertime`
@@ -163,7 +244,7 @@ func TestDo(t *testing.T) {
 			})
 
 			req, _ := client.NewRequest("GET", "/", nil)
-			resp, _, _ := client.DoPlain(req)
+			resp, _, _ := client.DoPlain(context.Background(), req)
 
 			body := string(resp)
 			So(body, ShouldEqual, want)
@@ -176,7 +257,7 @@ func TestDo(t *testing.T) {
 			})
 
 			req, _ := client.NewRequest("GET", "/", nil)
-			_, _, err := client.DoPlain(req)
+			_, _, err := client.DoPlain(context.Background(), req)
 			So(err, ShouldNotBeNil)
 		})
 
@@ -0,0 +1,79 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+)
+
+// CloneReport lists what CloneWorkspace copied by workspace relative paths
+type CloneReport struct {
+	Source string
+	Target string
+
+	Copied []string
+	// Skipped are sources with a path that would leave the workspace and generated files
+	Skipped []string
+	Bytes   int64
+
+	// Validation is the result of validating the clone
+	Validation *ValidationResult
+}
+
+// isGenerated tells if the workspace path name belongs to generated code
+func isGenerated(name string) bool {
+	return strings.HasPrefix(name, "src-gen/")
+}
+
+// CloneWorkspace creates a new workspace with copies of the sources of the
+// workspace srcID and validates it. Generated code isn't copied, the
+// validation creates it again. The handle of the new workspace is returned
+// as soon as it exists, even if copying fails later.
+func (c *Client) CloneWorkspace(ctx context.Context, srcID string, opts CreateOptions) (*WorkspaceHandle, *CloneReport, error) {
+	ctx = withOperation(ctx, "Workspace", "Clone")
+	src := c.OpenWorkspace(srcID)
+
+	w, _, err := src.GetInfo(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dst, _, err := c.CreateWorkspace(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	report := &CloneReport{Source: srcID, Target: dst.ID()}
+	files := make(map[string]io.Reader, len(w.Files))
+	for _, f := range w.Files {
+		name, ok := recordName(f.Record)
+		if !ok || isGenerated(name) {
+			report.Skipped = append(report.Skipped, f.Record.RelPath)
+			continue
+		}
+
+		content, err := src.readRecord(ctx, f.Record)
+		if err != nil {
+			return dst, nil, err
+		}
+
+		files[name] = bytes.NewReader(content)
+		report.Copied = append(report.Copied, name)
+		report.Bytes += int64(len(content))
+	}
+	sort.Strings(report.Copied)
+
+	c.logger.Debug("clone workspace", "workspace", srcID, "clone", dst.ID(), "files", len(files))
+	if err := dst.UploadFiles(ctx, files); err != nil {
+		return dst, nil, err
+	}
+
+	report.Validation, err = dst.Validate(ctx)
+	if err != nil {
+		return dst, nil, err
+	}
+
+	return dst, report, nil
+}
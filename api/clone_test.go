@@ -0,0 +1,88 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCloneWorkspace(t *testing.T) {
+	Convey("Given a source workspace", t, func() {
+		setup()
+
+		contents := map[string]string{
+			"alu.pshdl":     "module alu {}",
+			"pkg/reg.pshdl": "module pkg.reg {}",
+		}
+		mux.HandleFunc("/api/v0.1/workspace/AAAA/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, contents[r.URL.Path[len("/api/v0.1/workspace/AAAA/"):]])
+		})
+		mux.HandleFunc("/api/v0.1/workspace/AAAA", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"AAAA","files":[
+				{"record":{"relPath":"alu.pshdl","fileURI":"/api/v0.1/workspace/AAAA/alu.pshdl"},
+				 "info":{"files":[{"relPath":"src-gen/psex/c/alu.c","fileURI":"/api/v0.1/workspace/AAAA/src-gen/psex/c/alu.c"}]}},
+				{"record":{"relPath":"pkg/reg.pshdl","fileURI":"/api/v0.1/workspace/AAAA/pkg/reg.pshdl"}},
+				{"record":{"relPath":"src-gen/old.vhd","fileURI":"/api/v0.1/workspace/AAAA/src-gen/old.vhd"}}
+			]}`)
+		})
+
+		mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+			So(r.Method, ShouldEqual, "POST")
+			fmt.Fprint(w, `{"id":"BBBB"}`)
+		})
+
+		uploaded := map[string]string{}
+		mux.HandleFunc("/api/v0.1/workspace/BBBB", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == "GET" {
+				fmt.Fprint(w, `{"id":"BBBB"}`)
+				return
+			}
+			mr, err := r.MultipartReader()
+			So(err, ShouldBeNil)
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				So(err, ShouldBeNil)
+				c, _ := ioutil.ReadAll(part)
+				uploaded[partFileName(part)] = string(c)
+			}
+		})
+
+		validated := false
+		mux.HandleFunc("/api/v0.1/compiler/BBBB/validate", func(w http.ResponseWriter, r *http.Request) {
+			validated = true
+			fmt.Fprint(w, `{"id":"BBBB","validated":true}`)
+		})
+
+		Convey("CloneWorkspace() should copy the sources and validate the clone", func() {
+			h, report, err := client.CloneWorkspace(context.Background(), "AAAA", CreateOptions{})
+			So(err, ShouldBeNil)
+			So(h.ID(), ShouldEqual, "BBBB")
+
+			So(uploaded, ShouldResemble, contents)
+			So(validated, ShouldBeTrue)
+
+			So(report.Source, ShouldEqual, "AAAA")
+			So(report.Target, ShouldEqual, "BBBB")
+			So(report.Copied, ShouldResemble, []string{"alu.pshdl", "pkg/reg.pshdl"})
+			So(report.Skipped, ShouldResemble, []string{"src-gen/old.vhd"})
+			So(report.Bytes, ShouldEqual, len("module alu {}")+len("module pkg.reg {}"))
+			So(report.Validation.Workspace.Validated, ShouldBeTrue)
+		})
+
+		Convey("CloneWorkspace() of an unknown workspace should not create one", func() {
+			_, _, err := client.CloneWorkspace(context.Background(), "FFFF", CreateOptions{})
+			So(err, ShouldNotBeNil)
+			So(uploaded, ShouldBeEmpty)
+		})
+
+		Reset(teardown)
+	})
+}
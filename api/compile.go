@@ -0,0 +1,163 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CompileTarget is generated code the compiler announces with an event
+type CompileTarget int
+
+// The targets of the compiler events
+const (
+	CompileVHDL CompileTarget = iota
+	CompileC
+)
+
+// compileSubjects are the subjects of the events of the targets
+var compileSubjects = [...]string{
+	CompileVHDL: "P:COMPILER:VHDL",
+	CompileC:    "P:COMPILER:C",
+}
+
+// DefaultCompileTimeout limits CompileAndWait if ctx has no deadline
+const DefaultCompileTimeout = 2 * time.Minute
+
+func (t CompileTarget) String() string {
+	switch t {
+	case CompileVHDL:
+		return "vhdl"
+	case CompileC:
+		return "c"
+	}
+	return fmt.Sprintf("CompileTarget(%d)", int(t))
+}
+
+// ParseCompileTarget returns the target for a name like "vhdl" or "c"
+func ParseCompileTarget(s string) (CompileTarget, error) {
+	switch strings.ToLower(s) {
+	case "vhdl":
+		return CompileVHDL, nil
+	case "c":
+		return CompileC, nil
+	}
+	return 0, fmt.Errorf("unknown compile target %q", s)
+}
+
+// CompileResult is the output of the compiler events CompileAndWait waited for
+type CompileResult struct {
+	// Validation is the result of the validation that triggered the compilers
+	Validation *ValidationResult
+	// Records are the generated files
+	Records []Record
+	// Problems are the problems the compilers reported
+	Problems []Problem
+}
+
+// CompileAndWait opens an event stream, validates the workspace and waits
+// until the compiler events of all targets arrived, or of CompileVHDL and
+// CompileC if none are given. If ctx has no deadline, DefaultCompileTimeout
+// applies. When the wait ends early, the result has the output of the events
+// that arrived so far. If the validation found errors, the compilers don't
+// run and the result only has the validation, with ErrValidationFailed.
+func (h *WorkspaceHandle) CompileAndWait(ctx context.Context, targets ...CompileTarget) (*CompileResult, error) {
+	ctx = withOperation(ctx, "Compiler", "CompileAndWait")
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCompileTimeout)
+		defer cancel()
+	}
+
+	// the stream ends with this call
+	streamCtx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	events, err := h.OpenEventStream(streamCtx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := h.SendClientConnected(ctx); err != nil {
+		return nil, err
+	}
+
+	return h.CompileWithEvents(ctx, events, targets...)
+}
+
+// CompileWithEvents is CompileAndWait on an event stream that is already
+// open, see OpenEventStream. It doesn't apply DefaultCompileTimeout.
+func (h *WorkspaceHandle) CompileWithEvents(ctx context.Context, events <-chan StreamingEvent, targets ...CompileTarget) (*CompileResult, error) {
+	if len(targets) == 0 {
+		targets = []CompileTarget{CompileVHDL, CompileC}
+	}
+
+	missing := make(map[string]CompileTarget, len(targets))
+	for _, t := range targets {
+		if t < 0 || int(t) >= len(compileSubjects) {
+			return nil, fmt.Errorf("unknown compile target %v", t)
+		}
+		missing[compileSubjects[t]] = t
+	}
+
+	res := new(CompileResult)
+	var err error
+	res.Validation, err = h.Validate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if res.Validation.HasErrors() {
+		return res, fmt.Errorf("%w: %d error(s)", ErrValidationFailed, res.Validation.ErrorCount)
+	}
+
+	for len(missing) > 0 {
+		select {
+		case ev, ok := <-events:
+			if !ok {
+				return res, fmt.Errorf("%w while waiting for %s", ErrStreamClosed, missingTargets(missing))
+			}
+
+			t, ok := missing[ev.GetSubject()]
+			if !ok {
+				continue
+			}
+			delete(missing, ev.GetSubject())
+			h.client.logger.Debug("compiled", "workspace", h.id, "target", t)
+
+			res.Records = append(res.Records, ev.GetFiles()...)
+			res.Problems = append(res.Problems, eventProblems(ev)...)
+
+		case <-ctx.Done():
+			return res, fmt.Errorf("%w while waiting for %s", ctx.Err(), missingTargets(missing))
+		}
+	}
+
+	return res, nil
+}
+
+// missingTargets lists the targets in a stable order for errors
+func missingTargets(missing map[string]CompileTarget) string {
+	var names []string
+	for t, subject := range compileSubjects {
+		if _, ok := missing[subject]; ok {
+			names = append(names, CompileTarget(t).String())
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+// eventProblems returns the problems of a compiler event
+func eventProblems(ev StreamingEvent) (problems []Problem) {
+	switch ev := ev.(type) {
+	case *CompilerVhdlEvent:
+		for _, c := range ev.Contents {
+			problems = append(problems, c.Problems...)
+		}
+	case *CompilerCEvent:
+		for _, c := range ev.Contents {
+			problems = append(problems, c.Problems...)
+		}
+	}
+	return problems
+}
@@ -0,0 +1,151 @@
+package pshdlApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCompileAndWait(t *testing.T) {
+	Convey("Given a server that compiles after validation", t, func() {
+		setup()
+
+		mux.HandleFunc("/api/v0.1/streaming/workspace/1234/clientID", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "c1")
+		})
+
+		connected := make(chan struct{})
+		mux.HandleFunc("/api/v0.1/streaming/workspace/1234/c1", func(w http.ResponseWriter, r *http.Request) {
+			So(r.Method, ShouldEqual, "POST")
+			close(connected)
+		})
+
+		validated := make(chan struct{})
+		validation := `{"id":"1234","validated":true}`
+		mux.HandleFunc("/api/v0.1/compiler/1234/validate", func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-connected:
+			default:
+				t.Error("validated before the client was connected")
+			}
+			fmt.Fprint(w, validation)
+			close(validated)
+		})
+
+		// sent are the events the stream sends after the validation
+		sent := []string{
+			`{"subject":"P:PING"}`,
+			`{"subject":"P:COMPILER:VHDL","contents":[{"files":[{"relPath":"src-gen/vhdl/a.vhdl"}],"problems":[{"errorCode":"W1","severity":"WARNING"}]}]}`,
+			`{"subject":"P:COMPILER:C","contents":[{"files":[{"relPath":"src-gen/psex/c/a.c"},{"relPath":"src-gen/psex/c/a.h"}]}]}`,
+		}
+		mux.HandleFunc("/api/v0.1/streaming/workspace/1234/c1/sse", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-validated:
+			case <-r.Context().Done():
+				return
+			}
+			for _, ev := range sent {
+				fmt.Fprintf(w, "data: %s\n\n", ev)
+			}
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		})
+
+		Convey("CompileAndWait() should return the output of all targets", func() {
+			res, err := client.Compiler.CompileAndWait(context.Background())
+			So(err, ShouldBeNil)
+			So(res.Validation.Workspace.Validated, ShouldBeTrue)
+			So(res.Records, ShouldResemble, []Record{
+				{RelPath: "src-gen/vhdl/a.vhdl"},
+				{RelPath: "src-gen/psex/c/a.c"},
+				{RelPath: "src-gen/psex/c/a.h"},
+			})
+			So(res.Problems, ShouldHaveLength, 1)
+			So(res.Problems[0].ErrorCode, ShouldEqual, "W1")
+		})
+
+		Convey("CompileAndWait() should only wait for the given targets", func() {
+			sent = sent[:2]
+
+			res, err := client.OpenWorkspace("1234").CompileAndWait(context.Background(), CompileVHDL)
+			So(err, ShouldBeNil)
+			So(res.Records, ShouldResemble, []Record{{RelPath: "src-gen/vhdl/a.vhdl"}})
+		})
+
+		Convey("CompileAndWait() should not wait if the validation found errors", func() {
+			validation = `{"id":"1234","validated":true,"files":[{"record":{"relPath":"a.pshdl"},"info":{"problems":[{"errorCode":"E1","severity":"ERROR"}]}}]}`
+			sent = nil
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			res, err := client.Compiler.CompileAndWait(ctx)
+			So(errors.Is(err, ErrValidationFailed), ShouldBeTrue)
+			So(ctx.Err(), ShouldBeNil)
+			So(res.Validation.ErrorCount, ShouldEqual, 1)
+			So(res.Records, ShouldBeEmpty)
+		})
+
+		Convey("CompileAndWait() should give up at the deadline", func() {
+			sent = sent[:2]
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			res, err := client.OpenWorkspace("1234").CompileAndWait(ctx, CompileVHDL, CompileC)
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+			So(err.Error(), ShouldContainSubstring, "waiting for c")
+			So(res.Records, ShouldResemble, []Record{{RelPath: "src-gen/vhdl/a.vhdl"}})
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestCompileWithEvents(t *testing.T) {
+	Convey("Given an event stream that is already open", t, func() {
+		setup()
+
+		mux.HandleFunc("/api/v0.1/compiler/1234/validate", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"1234"}`)
+		})
+
+		events := make(chan StreamingEvent, 1)
+		h := client.OpenWorkspace("1234")
+
+		Convey("CompileWithEvents() should fail when the stream closes", func() {
+			close(events)
+
+			_, err := h.CompileWithEvents(context.Background(), events, CompileC)
+			So(errors.Is(err, ErrStreamClosed), ShouldBeTrue)
+		})
+
+		Convey("CompileWithEvents() should reject unknown targets", func() {
+			_, err := h.CompileWithEvents(context.Background(), events, CompileTarget(7))
+			So(err, ShouldNotBeNil)
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestParseCompileTarget(t *testing.T) {
+	Convey("ParseCompileTarget() should parse the names it prints", t, func() {
+		for _, target := range []CompileTarget{CompileVHDL, CompileC} {
+			parsed, err := ParseCompileTarget(target.String())
+			So(err, ShouldBeNil)
+			So(parsed, ShouldEqual, target)
+		}
+
+		_, err := ParseCompileTarget("go")
+		So(err, ShouldNotBeNil)
+	})
+}
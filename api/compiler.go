@@ -2,26 +2,53 @@ package pshdlApi
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 )
 
 // CompilerService handles communication with the compiler related
-// methods of the PsHdl REST API.
+// methods of the PsHdl REST API for the workspace with ID.
+// Use Client.OpenWorkspace to work with several workspaces at once.
 type CompilerService struct {
 	client *Client
 	// current workspace Id
 	ID string
 }
 
+// Validate sends a request for Validation of the workspace, see WorkspaceHandle.Validate
+func (s *CompilerService) Validate(ctx context.Context) (*ValidationResult, error) {
+	return s.client.OpenWorkspace(s.ID).Validate(ctx)
+}
+
+// CompileAndWait validates the workspace and waits for the compiler output, see WorkspaceHandle.CompileAndWait
+func (s *CompilerService) CompileAndWait(ctx context.Context, targets ...CompileTarget) (*CompileResult, error) {
+	return s.client.OpenWorkspace(s.ID).CompileAndWait(ctx, targets...)
+}
+
+// RequestSimCode sends a request for simulation code, see WorkspaceHandle.RequestSimCode
+func (s *CompilerService) RequestSimCode(ctx context.Context, ct SimCodeType, moduleName string, opts SimCodeOptions) ([]Record, error) {
+	return s.client.OpenWorkspace(s.ID).RequestSimCode(ctx, ct, moduleName, opts)
+}
+
+// FetchSimCode requests simulation code and downloads it, see WorkspaceHandle.FetchSimCode
+func (s *CompilerService) FetchSimCode(ctx context.Context, ct SimCodeType, moduleName, dir string, opts SimCodeOptions) ([]Record, error) {
+	return s.client.OpenWorkspace(s.ID).FetchSimCode(ctx, ct, moduleName, dir, opts)
+}
+
 // Validate sends a request for Validation of the workspace
-// TODO: Return result of validation
-func (s *CompilerService) Validate() (*Workspace, error) {
-	dbg("Compiler.Validate(%s)", s.ID)
+// and returns the problems the compiler found
+func (h *WorkspaceHandle) Validate(ctx context.Context) (*ValidationResult, error) {
+	ctx = withOperation(ctx, "Compiler", "Validate")
+	h.client.logger.Debug("validate", "workspace", h.id)
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
 
-	req, err := s.client.NewRequest("POST", fmt.Sprintf("compiler/%s/validate", s.ID), nil)
+	req, err := h.client.NewRequest("POST", fmt.Sprintf("compiler/%s/validate", h.id), nil)
 	if err != nil {
 		return nil, err
 	}
@@ -29,11 +56,11 @@ func (s *CompilerService) Validate() (*Workspace, error) {
 	req.Header.Set("Accept", "application/json")
 
 	wp := new(Workspace)
-	if _, err = s.client.Do(req, wp); err != nil {
+	if _, err = h.client.Do(ctx, req, wp); err != nil {
 		return nil, err
 	}
 
-	return wp, nil
+	return NewValidationResult(wp), nil
 }
 
 // SimCodeType represents the different types of simulation code that can be generated by the API
@@ -49,33 +76,104 @@ const (
 	SimJavaScript
 )
 
+// simCodeNames are the names of the SimCodeTypes, which are also the
+// last element of their compiler endpoints
+var simCodeNames = [...]string{
+	SimPsex:       "psex",
+	SimJava:       "java",
+	SimC:          "c",
+	SimGo:         "go",
+	SimDart:       "dart",
+	SimJavaScript: "javascript",
+}
+
+func (ct SimCodeType) String() string {
+	if ct < 0 || int(ct) >= len(simCodeNames) {
+		return fmt.Sprintf("SimCodeType(%d)", int(ct))
+	}
+	return simCodeNames[ct]
+}
+
+// ParseSimCodeType returns the SimCodeType for a name like "c" or "javascript"
+func ParseSimCodeType(s string) (SimCodeType, error) {
+	s = strings.ToLower(s)
+	if s == "js" {
+		return SimJavaScript, nil
+	}
+	for ct, name := range simCodeNames {
+		if name == s {
+			return SimCodeType(ct), nil
+		}
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnsupportedSimCodeType, s)
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (ct SimCodeType) MarshalText() ([]byte, error) {
+	if ct < 0 || int(ct) >= len(simCodeNames) {
+		return nil, fmt.Errorf("%w:%d", ErrUnsupportedSimCodeType, ct)
+	}
+	return []byte(simCodeNames[ct]), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (ct *SimCodeType) UnmarshalText(text []byte) error {
+	parsed, err := ParseSimCodeType(string(text))
+	if err != nil {
+		return err
+	}
+	*ct = parsed
+	return nil
+}
+
+// endpoint returns the compiler endpoint that generates ct
+func (ct SimCodeType) endpoint(wid string) (string, error) {
+	switch {
+	case ct == SimPsex:
+		return fmt.Sprintf("compiler/%s/psex", wid), nil
+	case ct > SimPsex && int(ct) < len(simCodeNames):
+		return fmt.Sprintf("compiler/%s/psex/%s", wid, simCodeNames[ct]), nil
+	}
+	return "", fmt.Errorf("%w:%d", ErrUnsupportedSimCodeType, ct)
+}
+
+// SimCodeOptions configure RequestSimCode and FetchSimCode
+type SimCodeOptions struct {
+	// Flatten strips the directories from the RelPath of the records,
+	// src-gen/psex/c/a.c becomes a.c
+	Flatten bool
+
+	// Download configures FetchSimCode, its Dir is replaced by the dir argument
+	Download DownloadOptions
+}
+
 // RequestSimCode sends a request for simulation code
-// if successfull, it returns the url for downloading the file
-func (s *CompilerService) RequestSimCode(ct SimCodeType, moduleName string) (uris []string, err error) {
-	dbg("Compiler.Validate(%s) %d %s", s.ID, ct, moduleName)
+// if successfull, it returns the records for downloading the files.
+// The server names them like src-gen:psex:c:a.c, their RelPath is src-gen/psex/c/a.c.
+func (h *WorkspaceHandle) RequestSimCode(ctx context.Context, ct SimCodeType, moduleName string, opts SimCodeOptions) (recs []Record, err error) {
+	ctx = withOperation(ctx, "Compiler", "RequestSimCode")
+	h.client.logger.Debug("request sim code", "workspace", h.id, "type", ct, "module", moduleName)
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
 	if moduleName == "" {
-		return nil, fmt.Errorf("missing moduleName")
+		return nil, ErrMissingModuleName
 	}
 
-	var reqURL string
-	switch ct {
-	case SimC:
-		reqURL = fmt.Sprintf("compiler/%s/psex/c", s.ID)
-	case SimGo:
-		reqURL = fmt.Sprintf("compiler/%s/psex/go", s.ID)
-	default:
-		return nil, fmt.Errorf("unsupported SimCodeType:%d", ct)
+	reqURL, err := ct.endpoint(h.id)
+	if err != nil {
+		return nil, err
 	}
 
 	param := url.Values{}
 	param.Set("module", moduleName)
 
-	req, err := s.client.NewReaderRequest("POST", reqURL, strings.NewReader(param.Encode()), "")
+	req, err := h.client.NewReaderRequest("POST", reqURL, strings.NewReader(param.Encode()), "")
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := s.client.Do(req, nil)
+	resp, err := h.client.Do(ctx, req, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -87,13 +185,29 @@ func (s *CompilerService) RequestSimCode(ct SimCodeType, moduleName string) (uri
 
 	uriScanner := bufio.NewScanner(resp.Body)
 
+	prefix := path.Join("/", h.client.baseURL.Path, "workspace", h.id) + "/"
 	for uriScanner.Scan() {
-		uri := uriScanner.Text()
-		if !strings.HasPrefix(uri, fmt.Sprintf("/api/v0.1/workspace/%s/src-gen:psex:", s.ID)) {
+		uri := strings.TrimSpace(uriScanner.Text())
+		if uri == "" {
+			continue
+		}
+
+		name := strings.TrimPrefix(uri, prefix)
+		if name == uri || !strings.HasPrefix(name, "src-gen:psex:") {
 			return nil, fmt.Errorf("error: RequestSimCode: invalid url returned: %s", uri)
 		}
 
-		uris = append(uris, uri)
+		rec := Record{FileURI: uri, RelPath: strings.Replace(name, ":", "/", -1)}
+		if opts.Flatten {
+			rec.RelPath = path.Base(rec.RelPath)
+		}
+		clean, ok := recordName(rec)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrUnsafePath, rec.RelPath)
+		}
+		rec.RelPath = clean
+
+		recs = append(recs, rec)
 	}
 
 	if err := uriScanner.Err(); err != nil {
@@ -102,3 +216,20 @@ func (s *CompilerService) RequestSimCode(ct SimCodeType, moduleName string) (uri
 
 	return
 }
+
+// FetchSimCode requests simulation code for moduleName and downloads it to dir.
+// It returns the downloaded records.
+func (h *WorkspaceHandle) FetchSimCode(ctx context.Context, ct SimCodeType, moduleName, dir string, opts SimCodeOptions) ([]Record, error) {
+	recs, err := h.RequestSimCode(ctx, ct, moduleName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	dl := opts.Download
+	dl.Dir = dir
+	if err := h.DownloadRecords(ctx, recs, dl); err != nil {
+		return nil, err
+	}
+
+	return recs, nil
+}
@@ -1,9 +1,15 @@
 package pshdlApi
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"path"
+	"path/filepath"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -21,27 +27,27 @@ func TestCompilerService(t *testing.T) {
 				fmt.Fprint(w, "{}")
 			})
 
-			_, err := client.Compiler.Validate()
+			_, err := client.Compiler.Validate(context.Background())
 			So(err, ShouldBeNil)
 		})
 
 		Convey("RequestSimCode()", func() {
 
 			Convey("should return an error when moduleName is empty", func() {
-				uris, err := client.Compiler.RequestSimCode(SimC, "")
+				uris, err := client.Compiler.RequestSimCode(context.Background(), SimC, "", SimCodeOptions{})
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "missing moduleName")
 				So(uris, ShouldBeNil)
 			})
 
 			Convey("should return an error when SimCodeType is unknown", func() {
-				uris, err := client.Compiler.RequestSimCode(23, "SomeModule")
+				uris, err := client.Compiler.RequestSimCode(context.Background(), 23, "SomeModule", SimCodeOptions{})
 				So(err, ShouldNotBeNil)
 				So(err.Error(), ShouldEqual, "unsupported SimCodeType:23")
 				So(uris, ShouldBeNil)
 			})
 
-			Convey("with valid information, should return the dlURL", func() {
+			Convey("with valid information, should return the records", func() {
 				moduleName := "some.module.mname"
 				dlURLs := `/api/v0.1/workspace/1234/src-gen:psex:c:de.tuhh.hbubert.MacFir.c
 /api/v0.1/workspace/1234/src-gen:psex:c:pshdl_de_tuhh_hbubert_MacFir_sim.h
@@ -58,10 +64,75 @@ func TestCompilerService(t *testing.T) {
 					http.Error(w, dlURLs, http.StatusCreated)
 				})
 
-				uris, err := client.Compiler.RequestSimCode(SimC, moduleName)
+				recs, err := client.Compiler.RequestSimCode(context.Background(), SimC, moduleName, SimCodeOptions{})
 				So(err, ShouldBeNil)
-				So(len(uris), ShouldEqual, 3)
-				// So(url, ShouldEqual, dlURL) //TODO remove the newline from the returned url
+				So(recs, ShouldHaveLength, 3)
+				So(recs[0], ShouldResemble, Record{
+					FileURI: "/api/v0.1/workspace/1234/src-gen:psex:c:de.tuhh.hbubert.MacFir.c",
+					RelPath: "src-gen/psex/c/de.tuhh.hbubert.MacFir.c",
+				})
+				So(recs[2].RelPath, ShouldEqual, "src-gen/psex/c/pshdl_generic_sim.h")
+
+				Convey("and flatten their paths if asked to", func() {
+					recs, err := client.Compiler.RequestSimCode(context.Background(), SimC, moduleName, SimCodeOptions{Flatten: true})
+					So(err, ShouldBeNil)
+					So(recs[0].RelPath, ShouldEqual, "de.tuhh.hbubert.MacFir.c")
+					So(recs[1].RelPath, ShouldEqual, "pshdl_de_tuhh_hbubert_MacFir_sim.h")
+				})
+			})
+
+			Convey("should reject uris outside of the workspace", func() {
+				mux.HandleFunc("/api/v0.1/compiler/1234/psex/c", func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "/api/v0.1/workspace/FFFF/src-gen:psex:c:a.c", http.StatusCreated)
+				})
+
+				recs, err := client.Compiler.RequestSimCode(context.Background(), SimC, "a", SimCodeOptions{})
+				So(err, ShouldNotBeNil)
+				So(recs, ShouldBeNil)
+			})
+
+			Convey("should accept uris below a base URL with another path", func() {
+				mux.HandleFunc("/pshdl/api/compiler/1234/psex/c", func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "/pshdl/api/workspace/1234/src-gen:psex:c:a.c", http.StatusCreated)
+				})
+
+				c, err := NewClient(WithBaseURL(server.URL+"/pshdl/api/"), WithWorkspaceID("1234"))
+				So(err, ShouldBeNil)
+
+				recs, err := c.Compiler.RequestSimCode(context.Background(), SimC, "a", SimCodeOptions{})
+				So(err, ShouldBeNil)
+				So(recs, ShouldResemble, []Record{{FileURI: "/pshdl/api/workspace/1234/src-gen:psex:c:a.c", RelPath: "src-gen/psex/c/a.c"}})
+			})
+
+			Convey("should reject paths that leave the workspace", func() {
+				mux.HandleFunc("/api/v0.1/compiler/1234/psex/c", func(w http.ResponseWriter, r *http.Request) {
+					http.Error(w, "/api/v0.1/workspace/1234/src-gen:psex:c:..:..:..:..:a.c", http.StatusCreated)
+				})
+
+				_, err := client.Compiler.RequestSimCode(context.Background(), SimC, "a", SimCodeOptions{})
+				So(errors.Is(err, ErrUnsafePath), ShouldBeTrue)
+			})
+
+			Convey("should request each language from its endpoint", func() {
+				for ct, endpoint := range map[SimCodeType]string{
+					SimPsex:       "/api/v0.1/compiler/1234/psex",
+					SimJava:       "/api/v0.1/compiler/1234/psex/java",
+					SimC:          "/api/v0.1/compiler/1234/psex/c",
+					SimGo:         "/api/v0.1/compiler/1234/psex/go",
+					SimDart:       "/api/v0.1/compiler/1234/psex/dart",
+					SimJavaScript: "/api/v0.1/compiler/1234/psex/javascript",
+				} {
+					uri := "/api/v0.1/workspace/1234/src-gen:psex:" + ct.String() + ":a.b"
+					mux.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
+						So(r.Method, ShouldEqual, "POST")
+						So(r.FormValue("module"), ShouldEqual, "a.b")
+						http.Error(w, uri, http.StatusCreated)
+					})
+
+					recs, err := client.Compiler.RequestSimCode(context.Background(), ct, "a.b", SimCodeOptions{})
+					So(err, ShouldBeNil)
+					So(recs, ShouldResemble, []Record{{FileURI: uri, RelPath: "src-gen/psex/" + ct.String() + "/a.b"}})
+				}
 			})
 
 			Convey("with a broken workspace, should return an error and empty url", func() {
@@ -72,12 +143,68 @@ func TestCompilerService(t *testing.T) {
 					http.Error(w, `[{}]`, http.StatusBadRequest)
 				})
 
-				uris, err := client.Compiler.RequestSimCode(SimC, "abc")
+				uris, err := client.Compiler.RequestSimCode(context.Background(), SimC, "abc", SimCodeOptions{})
 				So(err, ShouldNotBeNil)
+				So(err, ShouldHaveSameTypeAs, &ValidationError{})
 				So(uris, ShouldBeNil)
 			})
 		})
 
+		Convey("FetchSimCode() should request and download the code", func() {
+			mux.HandleFunc("/api/v0.1/compiler/1234/psex/go", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "/api/v0.1/workspace/1234/src-gen:psex:go:a.go\n/api/v0.1/workspace/1234/src-gen:psex:go:b.go", http.StatusCreated)
+			})
+			mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "package "+path.Base(r.URL.Path))
+			})
+
+			dir := t.TempDir()
+			recs, err := client.Compiler.FetchSimCode(context.Background(), SimGo, "a", dir, SimCodeOptions{Flatten: true})
+			So(err, ShouldBeNil)
+			So(recs, ShouldHaveLength, 2)
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, "b.go"))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "package src-gen:psex:go:b.go")
+		})
+
 		Reset(teardown)
 	})
 }
+
+func TestSimCodeType(t *testing.T) {
+	Convey("SimCodeType", t, func() {
+		Convey("should parse the names it prints", func() {
+			for _, ct := range []SimCodeType{SimPsex, SimJava, SimC, SimGo, SimDart, SimJavaScript} {
+				parsed, err := ParseSimCodeType(ct.String())
+				So(err, ShouldBeNil)
+				So(parsed, ShouldEqual, ct)
+			}
+
+			ct, err := ParseSimCodeType("JS")
+			So(err, ShouldBeNil)
+			So(ct, ShouldEqual, SimJavaScript)
+
+			_, err = ParseSimCodeType("vhdl")
+			So(errors.Is(err, ErrUnsupportedSimCodeType), ShouldBeTrue)
+			So(SimCodeType(23).String(), ShouldEqual, "SimCodeType(23)")
+		})
+
+		Convey("should round trip through JSON as text", func() {
+			var cfg struct {
+				Targets []SimCodeType `json:"targets"`
+			}
+			err := json.Unmarshal([]byte(`{"targets":["c","dart","javascript"]}`), &cfg)
+			So(err, ShouldBeNil)
+			So(cfg.Targets, ShouldResemble, []SimCodeType{SimC, SimDart, SimJavaScript})
+
+			out, err := json.Marshal(cfg)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, `{"targets":["c","dart","javascript"]}`)
+
+			So(json.Unmarshal([]byte(`{"targets":["cobol"]}`), &cfg), ShouldNotBeNil)
+			_, err = json.Marshal(SimCodeType(23))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
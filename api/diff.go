@@ -0,0 +1,338 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DiffStatus classifies a path in a comparison of a local directory with a workspace
+type DiffStatus int
+
+// A path is added or removed if it only exists locally or only in the workspace
+const (
+	DiffUnchanged DiffStatus = iota
+	DiffAdded
+	DiffRemoved
+	DiffModified
+)
+
+func (s DiffStatus) String() string {
+	switch s {
+	case DiffUnchanged:
+		return "unchanged"
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffModified:
+		return "modified"
+	}
+	return fmt.Sprintf("DiffStatus(%d)", int(s))
+}
+
+// FileDiff is the result of comparing one path
+type FileDiff struct {
+	Path   string
+	Status DiffStatus
+	// Diff is a unified diff from the workspace to the local file if it was modified
+	Diff string
+}
+
+// diffContext is the number of unchanged lines around changes in a unified diff
+const diffContext = 3
+
+// maxDiffEdits limits the edits diffLines looks for, its memory grows with their square
+const maxDiffEdits = 1000
+
+// Diff compares the local directory dir with the sources of the workspace
+// described by w, or by GetInfo if w is nil. Local files are selected like
+// UploadDir selects them, and the same rules select the workspace files that
+// are compared. Paths with equal hashes are unchanged, others are downloaded
+// and compared by content. The result is sorted by path.
+func (h *WorkspaceHandle) Diff(ctx context.Context, dir string, w *Workspace) ([]FileDiff, error) {
+	ctx = withOperation(ctx, "Workspace", "Diff")
+	if w == nil {
+		var err error
+		w, _, err = h.GetInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	local, err := scanDir(dir, UploadDirOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	ign, err := LoadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	remote := make(map[string]Record, len(w.Files))
+	for _, f := range w.Files {
+		name, ok := recordName(f.Record)
+		if !ok || isGenerated(name) || !isPshdlFile(name) || ign.matchTree(name) {
+			continue
+		}
+		remote[name] = f.Record
+	}
+
+	var diffs []FileDiff
+	for _, name := range local.Uploaded {
+		rec, ok := remote[name]
+		if !ok {
+			diffs = append(diffs, FileDiff{Path: name, Status: DiffAdded})
+			continue
+		}
+		delete(remote, name)
+
+		d, err := h.diffFile(ctx, filepath.Join(dir, filepath.FromSlash(name)), name, rec)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, d)
+	}
+
+	for name := range remote {
+		diffs = append(diffs, FileDiff{Path: name, Status: DiffRemoved})
+	}
+
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Path < diffs[j].Path })
+	return diffs, nil
+}
+
+func (h *WorkspaceHandle) diffFile(ctx context.Context, fname, name string, rec Record) (FileDiff, error) {
+	d := FileDiff{Path: name}
+
+	localContent, err := ioutil.ReadFile(fname)
+	if err != nil {
+		return d, err
+	}
+
+	if rec.Hash != "" {
+		localHash, err := HashReader(bytes.NewReader(localContent))
+		if err != nil {
+			return d, err
+		}
+		if SameHash(localHash, rec.Hash) {
+			return d, nil
+		}
+	}
+
+	// the content is compared as well, in case the hash isn't what we expect
+	remoteContent, err := h.readRecord(ctx, Record{FileURI: rec.FileURI, RelPath: rec.RelPath})
+	if err != nil {
+		return d, err
+	}
+
+	if !bytes.Equal(localContent, remoteContent) {
+		d.Status = DiffModified
+		d.Diff = UnifiedDiff("a/"+name, "b/"+name, string(remoteContent), string(localContent))
+	}
+	return d, nil
+}
+
+type diffOp int
+
+const (
+	opEqual diffOp = iota
+	opDelete
+	opInsert
+)
+
+// lineEdit is one line of an edit script, aPos and bPos are the number of
+// lines of a and b before it
+type lineEdit struct {
+	op         diffOp
+	aPos, bPos int
+	line       string
+}
+
+// splitLines splits text into lines with their line breaks, so a last line
+// without one differs from the same line with one
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffLines returns the shortest edit script from a to b, using the
+// algorithm of Myers, "An O(ND) Difference Algorithm and Its Variations".
+// It gives up and returns false if that needs more than maxEdits edits.
+func diffLines(a, b []string, maxEdits int) ([]lineEdit, bool) {
+	n, m := len(a), len(b)
+	max := n + m
+	if max > maxEdits {
+		max = maxEdits
+	}
+	off := max + 1
+	v := make([]int, 2*max+3)
+
+	// the diagonals -d..d of v before each step d, for the backtracking
+	var trace [][]int
+	dEnd := -1
+
+search:
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+
+			if x >= n && y >= m {
+				dEnd = d
+				break search
+			}
+		}
+	}
+
+	if dEnd < 0 {
+		return nil, false
+	}
+
+	var edits []lineEdit
+	x, y := n, m
+	for d := dEnd; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, lineEdit{opEqual, x, y, a[x]})
+		}
+
+		if x == prevX {
+			y--
+			edits = append(edits, lineEdit{opInsert, x, y, b[y]})
+		} else {
+			x--
+			edits = append(edits, lineEdit{opDelete, x, y, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		edits = append(edits, lineEdit{opEqual, x, y, a[x]})
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits, true
+}
+
+// UnifiedDiff returns the changes from a to b as a unified diff with the
+// file names aName and bName, or "" if they are equal. If the texts differ
+// in too many places, it only returns a line saying that they differ.
+func UnifiedDiff(aName, bName, a, b string) string {
+	edits, ok := diffLines(splitLines(a), splitLines(b), maxDiffEdits)
+	if !ok {
+		return fmt.Sprintf("Files %s and %s differ\n", aName, bName)
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(edits); {
+		if edits[i].op == opEqual {
+			i++
+			continue
+		}
+
+		// the hunk spans from the context before this change to the
+		// context after the last change that is close enough
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(edits); j++ {
+			if edits[j].op != opEqual {
+				end = j + 1
+			} else if j-end >= 2*diffContext {
+				break
+			}
+		}
+		end += diffContext
+		if end > len(edits) {
+			end = len(edits)
+		}
+
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		writeHunk(&out, edits[start:end])
+		i = end
+	}
+
+	return out.String()
+}
+
+func writeHunk(out *strings.Builder, edits []lineEdit) {
+	var aCount, bCount int
+	for _, e := range edits {
+		if e.op != opInsert {
+			aCount++
+		}
+		if e.op != opDelete {
+			bCount++
+		}
+	}
+
+	aStart, bStart := edits[0].aPos, edits[0].bPos
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+
+	fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+	for _, e := range edits {
+		switch e.op {
+		case opEqual:
+			out.WriteString(" ")
+		case opDelete:
+			out.WriteString("-")
+		case opInsert:
+			out.WriteString("+")
+		}
+		out.WriteString(e.line)
+		if !strings.HasSuffix(e.line, "\n") {
+			out.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+}
+
+func hunkRange(start, count int) string {
+	if count == 1 {
+		return fmt.Sprint(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,128 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	Convey("UnifiedDiff()", t, func() {
+		Convey("should be empty for equal texts", func() {
+			So(UnifiedDiff("a", "b", "x\ny\n", "x\ny\n"), ShouldEqual, "")
+		})
+
+		Convey("should show a changed line with its context", func() {
+			So(UnifiedDiff("a/x.pshdl", "b/x.pshdl", "module a {\n\tbit x;\n}\n", "module a {\n\tbit y;\n}\n"), ShouldEqual,
+				"--- a/x.pshdl\n+++ b/x.pshdl\n@@ -1,3 +1,3 @@\n module a {\n-\tbit x;\n+\tbit y;\n }\n")
+		})
+
+		Convey("should handle empty texts", func() {
+			So(UnifiedDiff("a", "b", "", "x\ny\n"), ShouldEqual, "--- a\n+++ b\n@@ -0,0 +1,2 @@\n+x\n+y\n")
+			So(UnifiedDiff("a", "b", "x\n", ""), ShouldEqual, "--- a\n+++ b\n@@ -1 +0,0 @@\n-x\n")
+		})
+
+		Convey("should mark a missing newline at the end", func() {
+			So(UnifiedDiff("a", "b", "x\ny\n", "x\ny"), ShouldEqual,
+				"--- a\n+++ b\n@@ -1,2 +1,2 @@\n x\n-y\n+y\n\\ No newline at end of file\n")
+			So(UnifiedDiff("a", "b", "x", "x\ny\n"), ShouldEqual,
+				"--- a\n+++ b\n@@ -1 +1,2 @@\n-x\n\\ No newline at end of file\n+x\n+y\n")
+		})
+
+		Convey("should only tell that texts with too many changes differ", func() {
+			var a, b strings.Builder
+			for i := 0; i < maxDiffEdits; i++ {
+				fmt.Fprintln(&a, "a", i)
+				fmt.Fprintln(&b, "b", i)
+			}
+			So(UnifiedDiff("a", "b", a.String(), b.String()), ShouldEqual, "Files a and b differ\n")
+		})
+
+		Convey("should split distant changes into hunks", func() {
+			var a, b []string
+			for i := 1; i <= 20; i++ {
+				a = append(a, fmt.Sprint(i))
+				b = append(b, fmt.Sprint(i))
+			}
+			b[1] = "two"
+			b = append(b[:15], b[16:]...)
+
+			So(UnifiedDiff("a", "b", strings.Join(a, "\n")+"\n", strings.Join(b, "\n")+"\n"), ShouldEqual,
+				"--- a\n+++ b\n"+
+					"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n"+
+					"@@ -13,7 +13,6 @@\n 13\n 14\n 15\n-16\n 17\n 18\n 19\n")
+		})
+	})
+}
+
+func TestWorkspaceDiff(t *testing.T) {
+	Convey("Given a local directory and a workspace", t, func() {
+		setup()
+
+		dir := t.TempDir()
+		write := func(name, content string) {
+			fname := filepath.Join(dir, filepath.FromSlash(name))
+			So(ioutil.WriteFile(fname, []byte(content), 0644), ShouldBeNil)
+		}
+		write("same.pshdl", testContent)
+		write("changed.pshdl", "module a {\n\tbit y;\n}\n")
+		write("new.pshdl", "module n {}")
+		write("skip.pshdl", "module s {}")
+		write(IgnoreFile, "skip.pshdl\nlib/\n")
+
+		contents := map[string]string{
+			"same.pshdl":    testContent,
+			"changed.pshdl": "module a {\n\tbit x;\n}\n",
+			"gone.pshdl":    "module g {}",
+		}
+		fetched := map[string]int{}
+		mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+			name := r.URL.Path[len("/api/v0.1/workspace/1234/"):]
+			fetched[name]++
+			fmt.Fprint(w, contents[name])
+		})
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"id":"1234","files":[
+				{"record":{"relPath":"same.pshdl","fileURI":"/api/v0.1/workspace/1234/same.pshdl","hash":"%s"}},
+				{"record":{"relPath":"changed.pshdl","fileURI":"/api/v0.1/workspace/1234/changed.pshdl","hash":"0000"}},
+				{"record":{"relPath":"gone.pshdl","fileURI":"/api/v0.1/workspace/1234/gone.pshdl"}},
+				{"record":{"relPath":"skip.pshdl","fileURI":"/api/v0.1/workspace/1234/skip.pshdl"}},
+				{"record":{"relPath":"lib/ignored.pshdl","fileURI":"/api/v0.1/workspace/1234/lib/ignored.pshdl"}},
+				{"record":{"relPath":"notes.txt","fileURI":"/api/v0.1/workspace/1234/notes.txt"}},
+				{"record":{"relPath":"src-gen/x.vhd","fileURI":"/api/v0.1/workspace/1234/src-gen/x.vhd"}}
+			]}`, testHash)
+		})
+
+		Convey("Diff() should classify each path", func() {
+			diffs, err := client.OpenWorkspace("1234").Diff(context.Background(), dir, nil)
+			So(err, ShouldBeNil)
+			So(diffs, ShouldResemble, []FileDiff{
+				{Path: "changed.pshdl", Status: DiffModified,
+					Diff: "--- a/changed.pshdl\n+++ b/changed.pshdl\n@@ -1,3 +1,3 @@\n module a {\n-\tbit x;\n+\tbit y;\n }\n"},
+				{Path: "gone.pshdl", Status: DiffRemoved},
+				{Path: "new.pshdl", Status: DiffAdded},
+				{Path: "same.pshdl", Status: DiffUnchanged},
+			})
+
+			Convey("and only download files with a different hash", func() {
+				So(fetched, ShouldResemble, map[string]int{"changed.pshdl": 1})
+			})
+		})
+
+		Convey("Diff() should compare the content if the hashes differ", func() {
+			contents["changed.pshdl"] = "module a {\n\tbit y;\n}\n"
+
+			diffs, err := client.Workspace.Diff(context.Background(), dir, nil)
+			So(err, ShouldBeNil)
+			So(diffs[0], ShouldResemble, FileDiff{Path: "changed.pshdl", Status: DiffUnchanged})
+		})
+
+		Reset(teardown)
+	})
+}
@@ -0,0 +1,297 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for unset DownloadOptions
+const (
+	DefaultConcurrency = 4
+	DefaultFileMode    = os.FileMode(0644)
+	DefaultDirMode     = os.FileMode(0755)
+)
+
+// DownloadOptions configure DownloadRecords and DownloadRecord
+type DownloadOptions struct {
+	// Dir is the root records are written to, the current directory if empty.
+	// Records with a RelPath outside of it fail with ErrUnsafePath.
+	Dir string
+
+	// FileMode and DirMode are the permissions of created files and directories
+	FileMode os.FileMode
+	DirMode  os.FileMode
+
+	// SkipHashCheck disables the check of downloaded content against Record.Hash
+	SkipHashCheck bool
+
+	// Concurrency limits the number of parallel downloads, DefaultConcurrency if <= 0
+	Concurrency int
+
+	// Progress is called after each finished download. Calls don't overlap,
+	// so it doesn't need to be safe for concurrent use.
+	Progress func(Progress)
+}
+
+// Progress describes the state of DownloadRecords after a finished download
+type Progress struct {
+	Record Record // the record that was just finished
+	Err    error  // why Record failed, nil on success
+
+	Bytes     int64 // written so far
+	FilesDone int
+	FilesLeft int
+}
+
+// RecordError is a failed download of Record
+type RecordError struct {
+	Record Record
+	Err    error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("download of %s failed: %v", e.Record.RelPath, e.Err)
+}
+
+// Unwrap returns the reason of the failure
+func (e *RecordError) Unwrap() error {
+	return e.Err
+}
+
+// path returns where rec is stored under opts.Dir
+func (opts DownloadOptions) path(rec Record) (string, error) {
+	rel := filepath.Clean(filepath.FromSlash(rec.RelPath))
+	if !filepath.IsLocal(rel) || rel == "." {
+		return "", fmt.Errorf("%w: %q", ErrUnsafePath, rec.RelPath)
+	}
+
+	return filepath.Join(opts.Dir, rel), nil
+}
+
+func (opts DownloadOptions) withDefaults() DownloadOptions {
+	if opts.FileMode == 0 {
+		opts.FileMode = DefaultFileMode
+	}
+	if opts.DirMode == 0 {
+		opts.DirMode = DefaultDirMode
+	}
+	return opts
+}
+
+type downloadResult struct {
+	rec Record
+	n   int64
+	err error
+}
+
+// DownloadRecords downloads recs with a pool of opts.Concurrency workers.
+// Failed downloads don't stop the others, the returned error joins a
+// *RecordError for every failed record. Records that didn't start before
+// ctx was done are skipped and ctx.Err() is joined to the error.
+func (h *WorkspaceHandle) DownloadRecords(ctx context.Context, recs []Record, opts DownloadOptions) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	opts = opts.withDefaults()
+
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+	if workers > len(recs) {
+		workers = len(recs)
+	}
+
+	start := time.Now()
+	jobs := make(chan Record)
+	results := make(chan downloadResult)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			for rec := range jobs {
+				n, err := h.downloadRecord(ctx, rec, opts)
+				results <- downloadResult{rec, n, err}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for _, rec := range recs {
+			select {
+			case jobs <- rec:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		errs []error
+		p    = Progress{FilesLeft: len(recs)}
+	)
+	for res := range results {
+		p.Record, p.Err = res.rec, nil
+		p.Bytes += res.n
+		p.FilesDone++
+		p.FilesLeft--
+
+		if res.err != nil {
+			p.Err = &RecordError{Record: res.rec, Err: res.err}
+			errs = append(errs, p.Err)
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(p)
+		}
+	}
+
+	h.client.logger.Debug("downloads finished", "workspace", h.id, "files", p.FilesDone, "failed", len(errs), "duration", time.Since(start))
+
+	if err := ctx.Err(); err != nil && p.FilesLeft > 0 {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// DownloadRecord writes a copy of rec to rec.RelPath under opts.Dir.
+// Concurrency and Progress of opts are ignored.
+func (h *WorkspaceHandle) DownloadRecord(ctx context.Context, rec Record, opts DownloadOptions) error {
+	_, err := h.downloadRecord(ctx, rec, opts.withDefaults())
+	return err
+}
+
+// downloadRecord downloads rec and returns the number of written bytes
+func (h *WorkspaceHandle) downloadRecord(ctx context.Context, rec Record, opts DownloadOptions) (int64, error) {
+	ctx = withOperation(ctx, "Workspace", "DownloadRecord")
+	h.client.logger.Debug("download record", "workspace", h.id, "path", rec.RelPath)
+	if h.id == "" {
+		return 0, ErrNoWorkspaceID
+	}
+
+	fname, err := opts.path(rec)
+	if err != nil {
+		return 0, err
+	}
+
+	// unchanged content is taken from the cache without asking the API
+	cache := h.client.cache
+	if cache != nil && rec.Hash != "" {
+		if e, ok := cache.Get(recordCacheKey(rec)); ok {
+			h.client.logger.Debug("record cache hit", "workspace", h.id, "path", rec.RelPath)
+			return writeRecord(fname, rec, bytes.NewReader(e.Body), int64(len(e.Body)), opts)
+		}
+		ctx = skipURLCache(ctx)
+	}
+
+	req, err := h.client.NewRequest("GET", rec.FileURI, nil)
+	if err != nil {
+		return 0, fmt.Errorf("client.NewRequest() error: %w", err)
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := h.client.Do(ctx, req, nil)
+	if err != nil {
+		return 0, fmt.Errorf("client.Do(req) error: %w", err)
+	}
+	defer resp.Body.Close()
+
+	fileLength := int64(-1)
+	if resp.Header.Get("Content-Length") != "" {
+		fileLength, err = strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if cache == nil || rec.Hash == "" {
+		return writeRecord(fname, rec, resp.Body, fileLength, opts)
+	}
+
+	var buf bytes.Buffer
+	n, err := writeRecord(fname, rec, io.TeeReader(resp.Body, &buf), fileLength, opts)
+	if err != nil {
+		return n, err
+	}
+
+	if err := cache.Set(recordCacheKey(rec), &CacheEntry{Body: buf.Bytes()}); err != nil {
+		h.client.logger.Warn("could not cache record", "workspace", h.id, "path", rec.RelPath, "err", err)
+	}
+
+	return n, nil
+}
+
+// writeRecord writes the content of rec from r to fname.
+// If fileLength isn't negative, r has to contain exactly fileLength bytes.
+// The content is written to a temporary file next to fname, which replaces
+// fname only if it is complete and matches rec.Hash. Afterwards the
+// modification time is set to rec.ModTime().
+func writeRecord(fname string, rec Record, r io.Reader, fileLength int64, opts DownloadOptions) (n int64, err error) {
+	dir := filepath.Dir(fname)
+	if err := os.MkdirAll(dir, opts.DirMode); err != nil {
+		return 0, fmt.Errorf("os.MkdirAll() error: %w", err)
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(fname)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("os.CreateTemp() error: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	h := newHash()
+	n, err = io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return n, err
+	}
+
+	if fileLength >= 0 && n != fileLength {
+		return n, fmt.Errorf("io.Copy(f, resp.Body) did not copy the whole file. got <%d> wanted <%d>", n, fileLength)
+	}
+
+	if rec.Hash != "" && !opts.SkipHashCheck {
+		if got := hex.EncodeToString(h.Sum(nil)); !SameHash(got, rec.Hash) {
+			return n, &IntegrityError{Record: rec, Got: got}
+		}
+	}
+
+	if err = f.Chmod(opts.FileMode); err != nil {
+		return n, err
+	}
+	if err = f.Close(); err != nil {
+		return n, err
+	}
+	if err = os.Rename(f.Name(), fname); err != nil {
+		return n, err
+	}
+
+	if mtime := rec.ModTime(); !mtime.IsZero() {
+		if err := os.Chtimes(fname, mtime, mtime); err != nil {
+			return n, fmt.Errorf("os.Chtimes() error: %w", err)
+		}
+	}
+
+	return n, nil
+}
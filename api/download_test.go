@@ -0,0 +1,161 @@
+package pshdlApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDownloadRecords(t *testing.T) {
+	Convey("Given a test server with some files", t, func() {
+		setup()
+
+		var running, maxRunning int32
+		mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+			n := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				m := atomic.LoadInt32(&maxRunning)
+				if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+					break
+				}
+			}
+
+			if filepath.Base(r.URL.Path) == "broken.pshdl" || filepath.Base(r.URL.Path) == "gone.pshdl" {
+				http.Error(w, "nope", http.StatusInternalServerError)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+			fmt.Fprint(w, "module x {}")
+		})
+
+		dir := t.TempDir()
+		record := func(name string) Record {
+			return Record{
+				FileURI: server.URL + "/api/v0.1/workspace/1234/" + name,
+				RelPath: name,
+			}
+		}
+
+		var recs []Record
+		for i := 0; i < 10; i++ {
+			recs = append(recs, record(fmt.Sprintf("f%d.pshdl", i)))
+		}
+
+		Convey("it should not run more than Concurrency downloads at once", func() {
+			err := client.Workspace.DownloadRecords(context.Background(), recs, DownloadOptions{Dir: dir, Concurrency: 3})
+			So(err, ShouldBeNil)
+			So(maxRunning, ShouldBeLessThanOrEqualTo, 3)
+			So(maxRunning, ShouldBeGreaterThan, 1)
+		})
+
+		Convey("it should report progress for every record", func() {
+			var got []Progress
+			err := client.Workspace.DownloadRecords(context.Background(), recs, DownloadOptions{
+				Dir:      dir,
+				Progress: func(p Progress) { got = append(got, p) },
+			})
+			So(err, ShouldBeNil)
+			So(got, ShouldHaveLength, len(recs))
+
+			last := got[len(got)-1]
+			So(last.FilesDone, ShouldEqual, len(recs))
+			So(last.FilesLeft, ShouldEqual, 0)
+			So(last.Bytes, ShouldEqual, int64(len(recs)*len("module x {}")))
+		})
+
+		Convey("it should join the errors of all failed records", func() {
+			recs = append(recs, record("broken.pshdl"), record("gone.pshdl"))
+
+			var failed int
+			err := client.Workspace.DownloadRecords(context.Background(), recs, DownloadOptions{
+				Dir: dir,
+				Progress: func(p Progress) {
+					if p.Err != nil {
+						failed++
+					}
+				},
+			})
+			So(err, ShouldNotBeNil)
+			So(failed, ShouldEqual, 2)
+			So(err.Error(), ShouldContainSubstring, "broken.pshdl")
+			So(err.Error(), ShouldContainSubstring, "gone.pshdl")
+
+			var recErr *RecordError
+			So(errors.As(err, &recErr), ShouldBeTrue)
+		})
+
+		Convey("a cancelled context should stop the downloads", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			err := client.Workspace.DownloadRecords(ctx, recs, DownloadOptions{Dir: dir, Concurrency: 1})
+			So(errors.Is(err, context.Canceled), ShouldBeTrue)
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestDownloadRecordAtomic(t *testing.T) {
+	Convey("Given a test server and an existing file", t, func() {
+		setup()
+
+		dir := t.TempDir()
+		fname := filepath.Join(dir, "test.pshdl")
+		So(ioutil.WriteFile(fname, []byte("old"), 0644), ShouldBeNil)
+
+		rec := Record{FileURI: "/api/v0.1/workspace/1234/test.pshdl", RelPath: "test.pshdl"}
+
+		Convey("a broken download should keep the old file", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234/test.pshdl", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "100")
+				fmt.Fprint(w, "module")
+			})
+
+			err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir})
+			So(err, ShouldNotBeNil)
+
+			content, err := ioutil.ReadFile(fname)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "old")
+
+			entries, err := ioutil.ReadDir(dir)
+			So(err, ShouldBeNil)
+			So(entries, ShouldHaveLength, 1)
+		})
+
+		Convey("a finished download should replace it and keep LastModified", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234/test.pshdl", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "module test {}")
+			})
+
+			mtime := time.Date(2014, 3, 1, 12, 30, 0, 0, time.UTC)
+			rec.LastModified = float64(mtime.UnixMilli())
+			So(rec.ModTime().Equal(mtime), ShouldBeTrue)
+
+			err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir})
+			So(err, ShouldBeNil)
+
+			content, err := ioutil.ReadFile(fname)
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, "module test {}")
+
+			fi, err := os.Stat(fname)
+			So(err, ShouldBeNil)
+			So(fi.ModTime().Equal(mtime), ShouldBeTrue)
+			So(fi.Mode().Perm(), ShouldEqual, DefaultFileMode)
+		})
+
+		Reset(teardown)
+	})
+}
@@ -0,0 +1,94 @@
+package pshdlApi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Errors returned by the services. Errors from the API are wrapped in an
+// *ErrorResponse, use errors.Is to check for them.
+var (
+	// ErrNoWorkspaceID is returned if a service is used without a workspace ID
+	ErrNoWorkspaceID = errors.New("workspace ID not set")
+
+	// ErrWorkspaceNotFound is returned if the API doesn't know the workspace
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+
+	// ErrFileNotFound is returned if a file doesn't exist in the workspace
+	ErrFileNotFound = errors.New("file not found")
+
+	// ErrFileNotDeleted is returned if the API didn't confirm a delete
+	ErrFileNotDeleted = errors.New("file was not deleted")
+
+	// ErrRateLimited is returned if the API rejected a request because too many were made
+	ErrRateLimited = errors.New("rate limited")
+
+	// ErrHashMismatch is returned if downloaded content doesn't match its Record.Hash, see IntegrityError
+	ErrHashMismatch = errors.New("hash mismatch")
+
+	// ErrUnsafePath is returned for records whose path would leave the download directory
+	ErrUnsafePath = errors.New("unsafe record path")
+
+	// ErrArchiveTooLarge is returned if a file in an archive or all of them together exceed the import limits
+	ErrArchiveTooLarge = errors.New("archive too large")
+
+	// ErrNoEventStream is returned if a client connected event is sent before opening the event stream
+	ErrNoEventStream = errors.New("event stream not opened")
+
+	// ErrStreamClosed is returned if an event stream ended while events were awaited
+	ErrStreamClosed = errors.New("event stream closed")
+
+	// ErrValidationFailed is returned if the compilers won't run because the workspace has errors
+	ErrValidationFailed = errors.New("workspace has errors")
+
+	// ErrMissingModuleName is returned if simulation code is requested without a module
+	ErrMissingModuleName = errors.New("missing moduleName")
+
+	// ErrUnsupportedSimCodeType is returned for SimCodeTypes the API can't generate
+	ErrUnsupportedSimCodeType = errors.New("unsupported SimCodeType")
+)
+
+// ValidationError is returned if the API rejected a request because of
+// problems in the code of the workspace, for example when requesting
+// simulation code of a broken module.
+type ValidationError struct {
+	*ErrorResponse
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	msg := fmt.Sprintf("%s: %d problem(s)", e.ErrorResponse.Error(), len(e.Problems))
+	if m := e.Problems[0].Advise.Message; m != "" {
+		msg += ", first: " + m
+	}
+	return msg
+}
+
+// Unwrap returns the underlying *ErrorResponse
+func (e *ValidationError) Unwrap() error {
+	return e.ErrorResponse
+}
+
+// notFoundKind tells from the path of req if a workspace or a file in it wasn't found
+func notFoundKind(req *http.Request) error {
+	if req == nil {
+		return nil
+	}
+
+	segs := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	for i, seg := range segs {
+		switch seg {
+		case "workspace":
+			// workspace/{id}/{file}
+			if len(segs) > i+2 {
+				return ErrFileNotFound
+			}
+			return ErrWorkspaceNotFound
+		case "compiler", "streaming":
+			return ErrWorkspaceNotFound
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestErrors(t *testing.T) {
+	Convey("Given a clean test server", t, func() {
+		setup()
+
+		Convey("an unknown workspace should be ErrWorkspaceNotFound", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Workspace does not exist", http.StatusNotFound)
+			})
+
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(errors.Is(err, ErrWorkspaceNotFound), ShouldBeTrue)
+
+			var errResp *ErrorResponse
+			So(errors.As(err, &errResp), ShouldBeTrue)
+			So(errResp.Response.StatusCode, ShouldEqual, http.StatusNotFound)
+			So(errResp.Message, ShouldEqual, "Workspace does not exist")
+		})
+
+		Convey("an unknown file should be ErrFileNotFound", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+				http.NotFound(w, r)
+			})
+
+			err := client.Workspace.DownloadRecord(context.Background(), Record{
+				FileURI: "/api/v0.1/workspace/1234/missing.pshdl",
+				RelPath: "missing.pshdl",
+			}, DownloadOptions{Dir: t.TempDir()})
+			So(errors.Is(err, ErrFileNotFound), ShouldBeTrue)
+			So(errors.Is(err, ErrWorkspaceNotFound), ShouldBeFalse)
+		})
+
+		Convey("too many requests should be ErrRateLimited", func() {
+			mux.HandleFunc("/api/v0.1/compiler/1234/validate", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusTooManyRequests)
+				fmt.Fprint(w, `{"message":"slow down"}`)
+			})
+
+			_, err := client.Compiler.Validate(context.Background())
+			So(errors.Is(err, ErrRateLimited), ShouldBeTrue)
+			So(err.Error(), ShouldEndWith, "429 slow down")
+		})
+
+		Convey("a list of problems should be a *ValidationError", func() {
+			mux.HandleFunc("/api/v0.1/compiler/1234/psex/c", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `[{"errorCode":"UNRESOLVED_REFERENCE","severity":"ERROR","advise":{"message":"a is not declared"}}]`)
+			})
+
+			_, err := client.Compiler.RequestSimCode(context.Background(), SimC, "de.tuhh.A", SimCodeOptions{})
+
+			var verr *ValidationError
+			So(errors.As(err, &verr), ShouldBeTrue)
+			So(verr.Problems, ShouldHaveLength, 1)
+			So(verr.Problems[0].ErrorCode, ShouldEqual, "UNRESOLVED_REFERENCE")
+			So(verr.Error(), ShouldContainSubstring, "a is not declared")
+
+			var errResp *ErrorResponse
+			So(errors.As(err, &errResp), ShouldBeTrue)
+			So(errResp.Response.StatusCode, ShouldEqual, http.StatusBadRequest)
+		})
+
+		Convey("services without a workspace ID should return ErrNoWorkspaceID", func() {
+			c, err := NewClient(WithBaseURL(server.URL + "/api/v0.1/"))
+			So(err, ShouldBeNil)
+
+			_, _, err = c.Workspace.GetInfo(context.Background())
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+
+			err = c.Workspace.UploadFile(context.Background(), "a.pshdl", bytes.NewReader(nil))
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+
+			_, err = c.Compiler.Validate(context.Background())
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+
+			_, err = c.Streaming.OpenEventStream(context.Background())
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+		})
+
+		Convey("an unknown SimCodeType should be ErrUnsupportedSimCodeType", func() {
+			_, err := client.Compiler.RequestSimCode(context.Background(), 23, "SomeModule", SimCodeOptions{})
+			So(errors.Is(err, ErrUnsupportedSimCodeType), ShouldBeTrue)
+		})
+
+		Reset(teardown)
+	})
+}
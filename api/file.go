@@ -1,6 +1,9 @@
 package pshdlApi
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // Problem is a result of a workspace validation with error describtions and solution hints
 type Problem struct {
@@ -24,10 +27,23 @@ type Problem struct {
 type Record struct {
 	FileURI      string  `json:"fileURI"`
 	Hash         string  `json:"hash"`
-	LastModified float64 `json:"lastModified"` //TODO Float?!
+	LastModified float64 `json:"lastModified"` // milliseconds since the epoch
 	RelPath      string  `json:"relPath"`
 }
 
+// ModTime returns LastModified as a time, the zero time if it isn't set
+func (r Record) ModTime() time.Time {
+	return millisTime(r.LastModified)
+}
+
+// millisTime converts milliseconds since the epoch, as the API sends them, to a time
+func millisTime(ms float64) time.Time {
+	if ms <= 0 {
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(ms))
+}
+
 // ModuleInfos describes ports and names of a module
 type ModuleInfos struct {
 	Instances []string `json:"instances"`
@@ -0,0 +1,400 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Open returns the content of the file relPath in the workspace.
+// The caller has to close it.
+func (h *WorkspaceHandle) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	ctx = withOperation(ctx, "Workspace", "Open")
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
+
+	if !fs.ValidPath(relPath) || relPath == "." {
+		return nil, fmt.Errorf("%w: %q", ErrUnsafePath, relPath)
+	}
+
+	return h.openURI(ctx, fmt.Sprintf("workspace/%s/%s", h.id, relPath))
+}
+
+// ReadFile returns the content of the file relPath in the workspace
+func (h *WorkspaceHandle) ReadFile(ctx context.Context, relPath string) ([]byte, error) {
+	body, err := h.Open(ctx, relPath)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return ioutil.ReadAll(body)
+}
+
+func (h *WorkspaceHandle) openURI(ctx context.Context, uri string) (io.ReadCloser, error) {
+	req, err := h.client.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain")
+
+	resp, err := h.client.Do(ctx, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// readRecord returns the content of rec, from the cache if its hash is known
+func (h *WorkspaceHandle) readRecord(ctx context.Context, rec Record) ([]byte, error) {
+	ctx = withOperation(ctx, "Workspace", "ReadFile")
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
+
+	cache := h.client.cache
+	if cache != nil && rec.Hash != "" {
+		if e, ok := cache.Get(recordCacheKey(rec)); ok {
+			return e.Body, nil
+		}
+		ctx = skipURLCache(ctx)
+	}
+
+	body, err := h.openURI(ctx, rec.FileURI)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	hash := newHash()
+	content, err := ioutil.ReadAll(io.TeeReader(body, hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if rec.Hash == "" {
+		return content, nil
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); !SameHash(got, rec.Hash) {
+		return nil, &IntegrityError{Record: rec, Got: got}
+	}
+
+	if cache != nil {
+		if err := cache.Set(recordCacheKey(rec), &CacheEntry{Body: content}); err != nil {
+			h.client.logger.Warn("could not cache record", "workspace", h.id, "path", rec.RelPath, "err", err)
+		}
+	}
+
+	return content, nil
+}
+
+// FS returns the files of the workspace and their generated outputs as a
+// read-only fs.FS. The list of files is taken from GetInfo once, the content
+// of a file is downloaded with ctx when it is first opened and then kept in
+// memory. Stat doesn't download, the Size of a file is fetched when it is
+// asked for, and is 0 if that fails. Records with a path that isn't valid
+// for fs.FS are left out.
+func (h *WorkspaceHandle) FS(ctx context.Context) (fs.FS, error) {
+	w, _, err := h.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wfs := &workspaceFS{
+		ctx:      ctx,
+		h:        h,
+		files:    make(map[string]Record),
+		dirs:     map[string]map[string]bool{".": {}},
+		contents: make(map[string][]byte),
+		fetching: make(map[string]*fetch),
+	}
+
+	for _, f := range w.Files {
+		wfs.add(f.Record)
+		for _, rec := range f.Info.Files {
+			wfs.add(rec)
+		}
+	}
+
+	return wfs, nil
+}
+
+type workspaceFS struct {
+	ctx context.Context
+	h   *WorkspaceHandle
+
+	files map[string]Record
+	dirs  map[string]map[string]bool // dir -> names of its entries
+
+	mu       sync.Mutex
+	contents map[string][]byte
+	fetching map[string]*fetch // downloads in progress
+}
+
+// fetch is a download of a file that others can wait for
+type fetch struct {
+	done    chan struct{}
+	content []byte
+	err     error
+}
+
+// recordName returns the slash separated path of rec inside a workspace
+// and false if it would leave the workspace.
+func recordName(rec Record) (string, bool) {
+	name := strings.TrimPrefix(path.Clean(rec.RelPath), "/")
+	return name, fs.ValidPath(name) && name != "."
+}
+
+func (wfs *workspaceFS) add(rec Record) {
+	name, ok := recordName(rec)
+	if !ok {
+		return
+	}
+
+	wfs.files[name] = rec
+	for name != "." {
+		dir := path.Dir(name)
+		if wfs.dirs[dir] == nil {
+			wfs.dirs[dir] = make(map[string]bool)
+		}
+		wfs.dirs[dir][path.Base(name)] = true
+		name = dir
+	}
+}
+
+// content returns the content of the file name. Only one download per file
+// runs at a time, without blocking the other files.
+func (wfs *workspaceFS) content(name string) ([]byte, error) {
+	wfs.mu.Lock()
+	if c, ok := wfs.contents[name]; ok {
+		wfs.mu.Unlock()
+		return c, nil
+	}
+	if f, ok := wfs.fetching[name]; ok {
+		wfs.mu.Unlock()
+		<-f.done
+		return f.content, f.err
+	}
+	f := &fetch{done: make(chan struct{})}
+	wfs.fetching[name] = f
+	wfs.mu.Unlock()
+
+	f.content, f.err = wfs.h.readRecord(wfs.ctx, wfs.files[name])
+
+	wfs.mu.Lock()
+	if f.err == nil {
+		wfs.contents[name] = f.content
+	}
+	delete(wfs.fetching, name)
+	wfs.mu.Unlock()
+	close(f.done)
+
+	return f.content, f.err
+}
+
+func (wfs *workspaceFS) stat(name string) (fs.FileInfo, error) {
+	if _, ok := wfs.dirs[name]; ok {
+		return &fileInfo{name: path.Base(name), mode: fs.ModeDir | 0555}, nil
+	}
+
+	rec, ok := wfs.files[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	fi := &fileInfo{name: path.Base(name), mode: 0444, modTime: rec.ModTime()}
+	fi.sizeOf = func() int64 {
+		c, err := wfs.content(name)
+		if err != nil {
+			return 0
+		}
+		return int64(len(c))
+	}
+	return fi, nil
+}
+
+// Stat implements fs.StatFS
+func (wfs *workspaceFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := wfs.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return info, nil
+}
+
+// Open implements fs.FS
+func (wfs *workspaceFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	info, err := wfs.stat(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	if info.IsDir() {
+		return &dirFile{wfs: wfs, name: name, info: info}, nil
+	}
+
+	c, err := wfs.content(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	fi := *info.(*fileInfo)
+	fi.size, fi.sizeOf = int64(len(c)), nil
+	return &file{Reader: bytes.NewReader(c), info: &fi}, nil
+}
+
+// ReadFile implements fs.ReadFileFS
+func (wfs *workspaceFS) ReadFile(name string) ([]byte, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if _, ok := wfs.files[name]; !ok {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrNotExist}
+	}
+
+	c, err := wfs.content(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: err}
+	}
+
+	return append([]byte(nil), c...), nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (wfs *workspaceFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if _, ok := wfs.dirs[name]; !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return wfs.entries(name), nil
+}
+
+func (wfs *workspaceFS) entries(dir string) []fs.DirEntry {
+	names := make([]string, 0, len(wfs.dirs[dir]))
+	for n := range wfs.dirs[dir] {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	entries := make([]fs.DirEntry, len(names))
+	for i, n := range names {
+		entries[i] = &dirEntry{wfs: wfs, name: path.Join(dir, n)}
+	}
+	return entries
+}
+
+type fileInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+
+	// sizeOf returns the size if it isn't known yet
+	sizeOf func() int64
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+
+func (fi *fileInfo) Size() int64 {
+	if fi.sizeOf != nil {
+		return fi.sizeOf()
+	}
+	return fi.size
+}
+
+func (fi *fileInfo) Mode() fs.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// dirEntry fetches the content of files only if the Size of its Info is asked for
+type dirEntry struct {
+	wfs  *workspaceFS
+	name string
+}
+
+func (e *dirEntry) Name() string { return path.Base(e.name) }
+
+func (e *dirEntry) IsDir() bool {
+	_, ok := e.wfs.dirs[e.name]
+	return ok
+}
+
+func (e *dirEntry) Type() fs.FileMode {
+	if e.IsDir() {
+		return fs.ModeDir
+	}
+	return 0
+}
+
+func (e *dirEntry) Info() (fs.FileInfo, error) { return e.wfs.stat(e.name) }
+
+type file struct {
+	*bytes.Reader
+	info fs.FileInfo
+}
+
+func (f *file) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *file) Close() error               { return nil }
+
+type dirFile struct {
+	wfs     *workspaceFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFile) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *dirFile) Close() error               { return nil }
+
+func (d *dirFile) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *dirFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		d.entries = d.wfs.entries(d.name)
+	}
+
+	left := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return left, nil
+	}
+
+	if len(left) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(left) {
+		n = len(left)
+	}
+	d.offset += n
+	return left[:n], nil
+}
@@ -0,0 +1,182 @@
+package pshdlApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWorkspaceFS(t *testing.T) {
+	Convey("Given a workspace with a file and generated code", t, func() {
+		setup()
+
+		files := map[string]string{
+			"alu.pshdl":                 "module alu {}",
+			"src-gen/psex/c/alu.c":      "int main() {}",
+			"src-gen/psex/c/alu.h":      "#pragma once",
+			"src-gen/psex/vhdl/alu.vhd": "entity alu is end;",
+		}
+
+		var (
+			mu      sync.Mutex
+			hits    int
+			release chan struct{} // if set, alu.pshdl is sent once it is closed
+			blocked = make(chan struct{}, 2)
+		)
+		hitCount := func() int {
+			mu.Lock()
+			defer mu.Unlock()
+			return hits
+		}
+		mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+			name := r.URL.Path[len("/api/v0.1/workspace/1234/"):]
+			mu.Lock()
+			hits++
+			wait := release
+			mu.Unlock()
+			if name == "alu.pshdl" && wait != nil {
+				blocked <- struct{}{}
+				<-wait
+			}
+
+			c, ok := files[name]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, c)
+		})
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"id":"1234","files":[{
+				"record":{"relPath":"alu.pshdl","fileURI":"/api/v0.1/workspace/1234/alu.pshdl","lastModified":1393676400000},
+				"info":{"files":[
+					{"relPath":"src-gen/psex/c/alu.c","fileURI":"/api/v0.1/workspace/1234/src-gen/psex/c/alu.c"},
+					{"relPath":"src-gen/psex/c/alu.h","fileURI":"/api/v0.1/workspace/1234/src-gen/psex/c/alu.h"},
+					{"relPath":"src-gen/psex/vhdl/alu.vhd","fileURI":"/api/v0.1/workspace/1234/src-gen/psex/vhdl/alu.vhd"},
+					{"relPath":"../escape.c","fileURI":"/api/v0.1/workspace/1234/escape.c"}
+				]}
+			}]}`)
+		})
+
+		ws := client.OpenWorkspace("1234")
+
+		Convey("ReadFile() should return the content of a file", func() {
+			c, err := ws.ReadFile(context.Background(), "alu.pshdl")
+			So(err, ShouldBeNil)
+			So(string(c), ShouldEqual, "module alu {}")
+
+			_, err = ws.ReadFile(context.Background(), "missing.pshdl")
+			So(errors.Is(err, ErrFileNotFound), ShouldBeTrue)
+
+			_, err = ws.ReadFile(context.Background(), "../1235/alu.pshdl")
+			So(errors.Is(err, ErrUnsafePath), ShouldBeTrue)
+		})
+
+		Convey("Open() should stream the content of a file", func() {
+			body, err := client.Workspace.Open(context.Background(), "src-gen/psex/c/alu.c")
+			So(err, ShouldBeNil)
+			defer body.Close()
+
+			c, err := ioutil.ReadAll(body)
+			So(err, ShouldBeNil)
+			So(string(c), ShouldEqual, "int main() {}")
+		})
+
+		Convey("FS()", func() {
+			fsys, err := ws.FS(context.Background())
+			So(err, ShouldBeNil)
+
+			Convey("should pass fstest.TestFS", func() {
+				So(fstest.TestFS(fsys, "alu.pshdl", "src-gen/psex/c/alu.c", "src-gen/psex/c/alu.h", "src-gen/psex/vhdl/alu.vhd"), ShouldBeNil)
+			})
+
+			Convey("should download each file only once", func() {
+				for i := 0; i < 3; i++ {
+					c, err := fs.ReadFile(fsys, "src-gen/psex/vhdl/alu.vhd")
+					So(err, ShouldBeNil)
+					So(string(c), ShouldEqual, "entity alu is end;")
+				}
+				So(hitCount(), ShouldEqual, 1)
+			})
+
+			Convey("should not download for Stat or WalkDir", func() {
+				fi, err := fs.Stat(fsys, "alu.pshdl")
+				So(err, ShouldBeNil)
+
+				var sizes []int64
+				err = fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+					if err != nil || d.IsDir() {
+						return err
+					}
+					info, err := d.Info()
+					if err == nil && name == "src-gen/psex/c/alu.h" {
+						sizes = append(sizes, info.Size())
+					}
+					return err
+				})
+				So(err, ShouldBeNil)
+				So(hitCount(), ShouldEqual, 1)
+
+				So(sizes, ShouldResemble, []int64{int64(len("#pragma once"))})
+				So(fi.Size(), ShouldEqual, len("module alu {}"))
+				So(hitCount(), ShouldEqual, 2)
+			})
+
+			Convey("should read other files while one downloads", func() {
+				release = make(chan struct{})
+
+				var wg sync.WaitGroup
+				slow := make([]string, 2)
+				for i := range slow {
+					wg.Add(1)
+					go func(i int) {
+						defer wg.Done()
+						c, _ := fs.ReadFile(fsys, "alu.pshdl")
+						slow[i] = string(c)
+					}(i)
+				}
+
+				<-blocked
+				c, err := fs.ReadFile(fsys, "src-gen/psex/c/alu.c")
+				So(err, ShouldBeNil)
+				So(string(c), ShouldEqual, "int main() {}")
+
+				close(release)
+				wg.Wait()
+				So(slow, ShouldResemble, []string{"module alu {}", "module alu {}"})
+				So(hitCount(), ShouldEqual, 2)
+			})
+
+			Convey("should list the generated code", func() {
+				matches, err := fs.Glob(fsys, "src-gen/psex/c/*")
+				So(err, ShouldBeNil)
+				So(matches, ShouldResemble, []string{"src-gen/psex/c/alu.c", "src-gen/psex/c/alu.h"})
+			})
+
+			Convey("should leave out records outside of the workspace", func() {
+				_, err := fs.Stat(fsys, "../escape.c")
+				So(err, ShouldNotBeNil)
+
+				entries, err := fs.ReadDir(fsys, ".")
+				So(err, ShouldBeNil)
+				So(entries, ShouldHaveLength, 2)
+			})
+
+			Convey("should use LastModified as ModTime", func() {
+				fi, err := fs.Stat(fsys, "alu.pshdl")
+				So(err, ShouldBeNil)
+				So(fi.ModTime().UnixMilli(), ShouldEqual, 1393676400000)
+			})
+		})
+
+		Reset(teardown)
+	})
+}
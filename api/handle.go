@@ -0,0 +1,57 @@
+package pshdlApi
+
+import (
+	"context"
+	"sync"
+)
+
+// WorkspaceHandle is a resource bound to a single workspace. It bundles the
+// workspace, compiler and streaming methods of the API for that workspace.
+//
+// Handles are cheap and safe for concurrent use, so one Client can serve
+// many workspaces at once.
+type WorkspaceHandle struct {
+	client *Client
+	id     string
+
+	mu       sync.Mutex
+	clientID string // of the last opened event stream
+}
+
+// OpenWorkspace returns a handle for the existing workspace id.
+// It doesn't talk to the API, use GetInfo to check that the workspace exists.
+func (c *Client) OpenWorkspace(id string) *WorkspaceHandle {
+	return &WorkspaceHandle{client: c, id: id}
+}
+
+// CreateWorkspace creates a new workspace and returns a handle and the info for it
+func (c *Client) CreateWorkspace(ctx context.Context, opts CreateOptions) (*WorkspaceHandle, *Workspace, error) {
+	w, _, err := c.createWorkspace(ctx, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return c.OpenWorkspace(w.ID), w, nil
+}
+
+// ID returns the ID of the workspace
+func (h *WorkspaceHandle) ID() string {
+	return h.id
+}
+
+// Client returns the client the handle uses
+func (h *WorkspaceHandle) Client() *Client {
+	return h.client
+}
+
+func (h *WorkspaceHandle) setClientID(id string) {
+	h.mu.Lock()
+	h.clientID = id
+	h.mu.Unlock()
+}
+
+func (h *WorkspaceHandle) getClientID() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.clientID
+}
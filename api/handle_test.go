@@ -0,0 +1,110 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestWorkspaceHandle(t *testing.T) {
+	Convey("Given a clean test server", t, func() {
+		setup()
+
+		for _, id := range []string{"AAAA", "BBBB", "CAFE"} {
+			id := id
+			mux.HandleFunc("/api/v0.1/workspace/"+id, func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"id":%q}`, id)
+			})
+			mux.HandleFunc("/api/v0.1/compiler/"+id+"/validate", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprintf(w, `{"id":%q,"validated":true}`, id)
+			})
+		}
+
+		Convey("OpenWorkspace() should bind the methods to the ID", func() {
+			a := client.OpenWorkspace("AAAA")
+			So(a.ID(), ShouldEqual, "AAAA")
+
+			wp, _, err := a.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(wp.ID, ShouldEqual, "AAAA")
+
+			res, err := a.Validate(context.Background())
+			So(err, ShouldBeNil)
+			So(res.Workspace.ID, ShouldEqual, "AAAA")
+		})
+
+		Convey("one client should serve many workspaces concurrently", func() {
+			var (
+				wg   sync.WaitGroup
+				mu   sync.Mutex
+				errs []error
+			)
+
+			for i := 0; i < 20; i++ {
+				for _, id := range []string{"AAAA", "BBBB"} {
+					wg.Add(1)
+					go func(h *WorkspaceHandle) {
+						defer wg.Done()
+						wp, _, err := h.GetInfo(context.Background())
+						if err == nil && wp.ID != h.ID() {
+							err = fmt.Errorf("got %s for %s", wp.ID, h.ID())
+						}
+						if err != nil {
+							mu.Lock()
+							errs = append(errs, err)
+							mu.Unlock()
+						}
+					}(client.OpenWorkspace(id))
+				}
+			}
+			wg.Wait()
+
+			So(errs, ShouldBeEmpty)
+		})
+
+		Convey("an empty ID should return ErrNoWorkspaceID", func() {
+			h := client.OpenWorkspace("")
+
+			_, _, err := h.GetInfo(context.Background())
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+
+			err = h.SendClientConnected(context.Background())
+			So(err, ShouldEqual, ErrNoWorkspaceID)
+		})
+
+		Convey("SendClientConnected() without a stream should return ErrNoEventStream", func() {
+			err := client.OpenWorkspace("AAAA").SendClientConnected(context.Background())
+			So(err, ShouldEqual, ErrNoEventStream)
+		})
+
+		Convey("Creating a workspace", func() {
+			mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "/api/v0.1/workspace/CAFE")
+			})
+
+			Convey("CreateWorkspace() should return a handle for it", func() {
+				h, wp, err := client.CreateWorkspace(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(h.ID(), ShouldEqual, "CAFE")
+				So(wp.ID, ShouldEqual, "CAFE")
+
+				// the services keep their workspace
+				So(client.Workspace.ID, ShouldEqual, "1234")
+			})
+
+			Convey("Workspace.Create() should update all services", func() {
+				_, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(client.Workspace.ID, ShouldEqual, "CAFE")
+				So(client.Compiler.ID, ShouldEqual, "CAFE")
+				So(client.Streaming.ID, ShouldEqual, "CAFE")
+			})
+		})
+
+		Reset(teardown)
+	})
+}
@@ -0,0 +1,56 @@
+package pshdlApi
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+)
+
+// newHash returns the digest the API uses for Record.Hash
+func newHash() hash.Hash {
+	return sha1.New()
+}
+
+// HashReader returns the digest of the content of r like the API computes Record.Hash
+func HashReader(r io.Reader) (string, error) {
+	h := newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFile returns the digest of the file fname like the API computes Record.Hash
+func HashFile(fname string) (string, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return HashReader(f)
+}
+
+// SameHash tells if two digests are equal
+func SameHash(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+// IntegrityError is returned if downloaded content doesn't match Record.Hash
+type IntegrityError struct {
+	Record Record
+	Got    string
+}
+
+func (e *IntegrityError) Error() string {
+	return fmt.Sprintf("hash mismatch for %s: got %s, want %s", e.Record.RelPath, e.Got, e.Record.Hash)
+}
+
+// Unwrap returns ErrHashMismatch
+func (e *IntegrityError) Unwrap() error {
+	return ErrHashMismatch
+}
@@ -0,0 +1,73 @@
+package pshdlApi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+const (
+	testContent = "module test {}"
+	testHash    = "1dae2fe6bdfe47b1d5618ba77904a408b36419f7"
+)
+
+func TestHash(t *testing.T) {
+	Convey("HashReader() should return the digest of the content", t, func() {
+		h, err := HashReader(strings.NewReader(testContent))
+		So(err, ShouldBeNil)
+		So(h, ShouldEqual, testHash)
+
+		Convey("and HashFile() the same for a file", func() {
+			fname := filepath.Join(t.TempDir(), "test.pshdl")
+			So(ioutil.WriteFile(fname, []byte(testContent), 0644), ShouldBeNil)
+
+			h, err := HashFile(fname)
+			So(err, ShouldBeNil)
+			So(SameHash(h, strings.ToUpper(testHash)), ShouldBeTrue)
+		})
+	})
+
+	Convey("Given a test server", t, func() {
+		setup()
+
+		mux.HandleFunc("/api/v0.1/workspace/1234/test.pshdl", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testContent)
+		})
+
+		dir := t.TempDir()
+		rec := Record{FileURI: "/api/v0.1/workspace/1234/test.pshdl", RelPath: "test.pshdl", Hash: testHash}
+
+		Convey("DownloadRecord() should accept matching content", func() {
+			err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir})
+			So(err, ShouldBeNil)
+		})
+
+		Convey("DownloadRecord() should reject content with a different hash", func() {
+			rec.Hash = strings.Repeat("0", 40)
+			err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir})
+			So(errors.Is(err, ErrHashMismatch), ShouldBeTrue)
+
+			var ierr *IntegrityError
+			So(errors.As(err, &ierr), ShouldBeTrue)
+			So(ierr.Got, ShouldEqual, testHash)
+
+			_, err = os.Stat(filepath.Join(dir, "test.pshdl"))
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			Convey("unless SkipHashCheck is set", func() {
+				err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir, SkipHashCheck: true})
+				So(err, ShouldBeNil)
+			})
+		})
+
+		Reset(teardown)
+	})
+}
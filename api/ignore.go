@@ -0,0 +1,210 @@
+package pshdlApi
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// IgnoreFile is the name of the file with ignore patterns LoadIgnore reads in each directory
+const IgnoreFile = ".pshdlignore"
+
+// defaultIgnore keeps the metadata of version control systems out of LoadIgnore
+const defaultIgnore = ".git/\n.hg/\n.svn/\n"
+
+// Ignore matches slash separated paths against patterns in gitignore syntax.
+// Patterns added later take precedence, like in a .gitignore.
+type Ignore struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// ParseIgnore reads patterns in gitignore syntax from r
+func ParseIgnore(r io.Reader) (*Ignore, error) {
+	ign := new(Ignore)
+	if err := ign.add("", r); err != nil {
+		return nil, err
+	}
+	return ign, nil
+}
+
+// LoadIgnore reads the ignore files of all directories in the tree root.
+// Their patterns apply to the paths below them, relative to root.
+// Directories of version control systems are always ignored.
+func LoadIgnore(root string) (*Ignore, error) {
+	ign, err := ParseIgnore(strings.NewReader(defaultIgnore))
+	if err != nil {
+		return nil, err
+	}
+
+	err = filepath.WalkDir(root, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			rel = ""
+		} else if rel = filepath.ToSlash(rel); ign.Match(rel, true) {
+			return filepath.SkipDir
+		}
+
+		return ign.addFile(rel, filepath.Join(fpath, IgnoreFile))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return ign, nil
+}
+
+// addFile adds the patterns of fname relative to base.
+// A missing file isn't an error.
+func (ign *Ignore) addFile(base, fname string) error {
+	f, err := os.Open(fname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return ign.add(base, f)
+}
+
+// add adds the patterns from r, which apply to paths below base
+func (ign *Ignore) add(base string, r io.Reader) error {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if p, ok := parseIgnorePattern(base, s.Text()); ok {
+			ign.patterns = append(ign.patterns, p)
+		}
+	}
+	return s.Err()
+}
+
+// Match tells if the slash separated relPath is ignored
+func (ign *Ignore) Match(relPath string, isDir bool) bool {
+	if ign == nil {
+		return false
+	}
+
+	ignored := false
+	for _, p := range ign.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if p.re.MatchString(relPath) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// matchTree tells if relPath or one of its parent directories is ignored,
+// so a walk of the tree wouldn't reach the file relPath
+func (ign *Ignore) matchTree(relPath string) bool {
+	parts := strings.Split(relPath, "/")
+	for i := 1; i < len(parts); i++ {
+		if ign.Match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return ign.Match(relPath, false)
+}
+
+func parseIgnorePattern(base, line string) (ignorePattern, bool) {
+	var p ignorePattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return p, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// patterns with a slash are relative to base, others match at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return p, false
+	}
+
+	var re strings.Builder
+	re.WriteString("^")
+	if base != "" {
+		re.WriteString(regexp.QuoteMeta(path.Clean(base) + "/"))
+	}
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := line[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		case c == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(string(line[i])))
+		default:
+			re.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	re.WriteString("$")
+
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return p, false
+	}
+	p.re = compiled
+	return p, true
+}
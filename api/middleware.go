@@ -0,0 +1,57 @@
+package pshdlApi
+
+import (
+	"context"
+	"net/http"
+)
+
+// RoundTripFunc sends a single request to the API and returns its response
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps the round trip of every request made by a Client,
+// including retries and the connection of the event stream.
+// The Operation that issued the request can be looked up with
+// OperationFromContext(req.Context()).
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// WithMiddleware adds mw to the middleware chain of the client.
+// The first middleware is the outermost one.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(c *Client) error {
+		c.middleware = append(c.middleware, mw...)
+		return nil
+	}
+}
+
+// Operation names the service method that issued a request
+type Operation struct {
+	Service string // for example "Workspace"
+	Method  string // for example "UploadFile"
+}
+
+func (o Operation) String() string {
+	return o.Service + "." + o.Method
+}
+
+type operationKey struct{}
+
+// withOperation returns a copy of ctx that carries the Operation service.method
+func withOperation(ctx context.Context, service, method string) context.Context {
+	return context.WithValue(ctx, operationKey{}, Operation{Service: service, Method: method})
+}
+
+// OperationFromContext returns the Operation stored in ctx.
+// Requests sent directly through Client.Do have none.
+func OperationFromContext(ctx context.Context) (Operation, bool) {
+	op, ok := ctx.Value(operationKey{}).(Operation)
+	return op, ok
+}
+
+// chain wraps the http client of c in its middleware
+func (c *Client) chain() RoundTripFunc {
+	rt := RoundTripFunc(c.client.Do)
+	for i := len(c.middleware) - 1; i >= 0; i-- {
+		rt = c.middleware[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,109 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMiddleware(t *testing.T) {
+	Convey("Given a test server and a client with middleware", t, func() {
+		setup()
+
+		var (
+			mu  sync.Mutex
+			ops []string
+		)
+
+		recordOps := func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				name := "none"
+				if op, ok := OperationFromContext(req.Context()); ok {
+					name = op.String()
+				}
+				mu.Lock()
+				ops = append(ops, name)
+				mu.Unlock()
+				return next(req)
+			}
+		}
+
+		addHeader := func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				req.Header.Set("X-Test", "yes")
+				return next(req)
+			}
+		}
+
+		var gotHeader string
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			gotHeader = r.Header.Get("X-Test")
+			fmt.Fprint(w, `{"id":"1234"}`)
+		})
+
+		client.middleware = []Middleware{recordOps, addHeader}
+
+		Convey("it should see the operation and modify the request", func() {
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(ops, ShouldResemble, []string{"Workspace.GetInfo"})
+			So(gotHeader, ShouldEqual, "yes")
+
+			err = client.Workspace.UploadFile(context.Background(), "a.pshdl", bytes.NewReader([]byte("module a {}")))
+			So(err, ShouldBeNil)
+			So(ops, ShouldResemble, []string{"Workspace.GetInfo", "Workspace.UploadFile"})
+		})
+
+		Convey("it should run for every retry", func() {
+			client.retry = testRetryPolicy
+
+			faults := 2
+			injectFault := func(next RoundTripFunc) RoundTripFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					if faults > 0 {
+						faults--
+						return nil, errors.New("injected fault")
+					}
+					return next(req)
+				}
+			}
+			client.middleware = append(client.middleware, injectFault)
+
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(ops, ShouldHaveLength, 3)
+		})
+
+		Convey("it should cover the event stream", func() {
+			mux.HandleFunc("/api/v0.1/streaming/workspace/1234/clientID", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "c1")
+			})
+			mux.HandleFunc("/api/v0.1/streaming/workspace/1234/c1/sse", func(w http.ResponseWriter, r *http.Request) {
+				gotHeader = r.Header.Get("X-Test")
+			})
+
+			events, err := client.Streaming.OpenEventStream(context.Background())
+			So(err, ShouldBeNil)
+			for range events {
+			}
+
+			So(ops, ShouldResemble, []string{"Streaming.OpenEventStream", "Streaming.OpenEventStream"})
+			So(gotHeader, ShouldEqual, "yes")
+		})
+
+		Convey("requests sent with Do() have no operation", func() {
+			req, _ := client.NewRequest("GET", "workspace/1234", nil)
+			_, err := client.Do(context.Background(), req, nil)
+			So(err, ShouldBeNil)
+			So(ops, ShouldResemble, []string{"none"})
+		})
+
+		Reset(teardown)
+	})
+}
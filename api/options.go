@@ -0,0 +1,95 @@
+package pshdlApi
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// An Option configures a Client created by NewClient
+type Option func(*Client) error
+
+// WithBaseURL sets the URL of the PSHDL REST API to talk to.
+// It has to be an absolute http or https URL, a missing trailing slash is added.
+func WithBaseURL(rawurl string) Option {
+	return func(c *Client) error {
+		u, err := parseBaseURL(rawurl)
+		if err != nil {
+			return err
+		}
+		c.baseURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient sets the http.Client used to talk to the API.
+// A nil httpClient means http.DefaultClient.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		if httpClient == nil {
+			httpClient = http.DefaultClient
+		}
+		c.client = httpClient
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(c *Client) error {
+		if ua == "" {
+			return fmt.Errorf("empty user agent")
+		}
+		c.UserAgent = ua
+		return nil
+	}
+}
+
+// WithWorkspaceID sets the workspace the services operate on
+func WithWorkspaceID(id string) Option {
+	return func(c *Client) error {
+		if id == "" {
+			return fmt.Errorf("empty workspace ID")
+		}
+		c.Workspace.ID = id
+		c.Compiler.ID = id
+		c.Streaming.ID = id
+		return nil
+	}
+}
+
+// WithLogger sets the logger the client writes structured messages to.
+// By default, or if l is nil, nothing is logged.
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Client) error {
+		if l == nil {
+			l = slog.New(slog.DiscardHandler)
+		}
+		c.logger = l
+		return nil
+	}
+}
+
+// parseBaseURL parses and checks rawurl for the use as a base URL
+func parseBaseURL(rawurl string) (*url.URL, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base URL: %s", err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid base URL %q: scheme has to be http or https", rawurl)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid base URL %q: missing host", rawurl)
+	}
+
+	if !strings.HasSuffix(u.Path, "/") {
+		u.Path += "/"
+	}
+
+	return u, nil
+}
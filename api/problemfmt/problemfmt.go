@@ -0,0 +1,110 @@
+// Package problemfmt writes the problems of a PSHDL workspace in the
+// file:line:col: severity: message [errorCode] format of gcc, so editors can
+// jump to them.
+//
+// Emacs compilation-mode reads the lines with its gnu rule. In Vim, set
+// 'errorformat' to VimErrorformat, which also joins the indented advice lines
+// to their problem, and load the output with :cfile.
+package problemfmt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/cryptix/goPshdlRest/api"
+)
+
+// VimErrorformat parses the output of Write for Vim's quickfix list
+const VimErrorformat = `%E%f:%l:%c: error: %m,%W%f:%l:%c: warning: %m,%I%f:%l:%c: info: %m,%+C    %m,%-G%.%#`
+
+// indent starts the lines with advice below a problem
+const indent = "    "
+
+// Options select what Write adds to each problem
+type Options struct {
+	// Explanation appends Advise.Explanation on indented lines
+	Explanation bool
+	// Solutions appends each of Advise.Solutions on an indented line
+	Solutions bool
+	// MinSeverity leaves out less severe problems
+	MinSeverity pshdlApi.Severity
+}
+
+// Line returns the problem p in the file relPath as one line without a line break.
+// Lines and columns start at 1, a problem without a location points at the start of the file.
+func Line(relPath string, p pshdlApi.Problem) string {
+	line := int(p.Location.Line)
+	if line < 1 {
+		line = 1
+	}
+	col := int(p.Location.OffsetInLine) + 1
+	if col < 1 {
+		col = 1
+	}
+
+	msg := oneLine(p.Advise.Message)
+	if msg == "" {
+		msg = "problem"
+	}
+	if p.ErrorCode != "" {
+		msg += " [" + p.ErrorCode + "]"
+	}
+
+	return fmt.Sprintf("%s:%d:%d: %s: %s", relPath, line, col, p.Level(), msg)
+}
+
+// Write writes the problems of files to w, one line per problem and the
+// advice selected by opts below it. The files are sorted by path and their
+// problems by location.
+func Write(w io.Writer, files []pshdlApi.File, opts Options) error {
+	files = append([]pshdlApi.File(nil), files...)
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Record.RelPath < files[j].Record.RelPath })
+
+	bw := bufio.NewWriter(w)
+	for _, f := range files {
+		relPath := strings.TrimPrefix(f.Record.RelPath, "/")
+
+		problems := append([]pshdlApi.Problem(nil), f.Info.Problems...)
+		sort.SliceStable(problems, func(i, j int) bool {
+			a, b := problems[i].Location, problems[j].Location
+			if a.Line != b.Line {
+				return a.Line < b.Line
+			}
+			return a.OffsetInLine < b.OffsetInLine
+		})
+
+		for _, p := range problems {
+			if p.Level() < opts.MinSeverity {
+				continue
+			}
+
+			fmt.Fprintln(bw, Line(relPath, p))
+			if opts.Explanation {
+				writeIndented(bw, p.Advise.Explanation)
+			}
+			if opts.Solutions {
+				for _, s := range p.Advise.Solutions {
+					writeIndented(bw, "solution: "+s)
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// writeIndented writes the non empty lines of text with indent
+func writeIndented(w io.Writer, text string) {
+	for _, l := range strings.Split(text, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			fmt.Fprintln(w, indent+l)
+		}
+	}
+}
+
+// oneLine joins the lines of s with spaces
+func oneLine(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
@@ -0,0 +1,175 @@
+package problemfmt
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cryptix/goPshdlRest/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func loadWorkspace() []pshdlApi.File {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "workspace.json"))
+	So(err, ShouldBeNil)
+
+	var w pshdlApi.Workspace
+	So(json.Unmarshal(data, &w), ShouldBeNil)
+	return w.Files
+}
+
+var goldenCases = map[string]Options{
+	"plain":     {},
+	"explain":   {Explanation: true},
+	"solutions": {Explanation: true, Solutions: true},
+	"errors":    {MinSeverity: pshdlApi.SeverityError},
+}
+
+func TestWriteGolden(t *testing.T) {
+	Convey("Write() should match the golden files", t, func() {
+		files := loadWorkspace()
+
+		for name, opts := range goldenCases {
+			var buf bytes.Buffer
+			So(Write(&buf, files, opts), ShouldBeNil)
+
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				So(ioutil.WriteFile(golden, buf.Bytes(), 0644), ShouldBeNil)
+			}
+
+			want, err := ioutil.ReadFile(golden)
+			So(err, ShouldBeNil)
+			So(buf.String(), ShouldEqual, string(want))
+		}
+	})
+}
+
+func TestLine(t *testing.T) {
+	Convey("Line()", t, func() {
+		var p pshdlApi.Problem
+		p.Severity = "WARNING"
+		p.Advise.Message = "  spread\n over  lines "
+		p.Location.Line = 2
+		p.Location.OffsetInLine = 9
+
+		Convey("should use 1 based columns and a single line message", func() {
+			So(Line("a.pshdl", p), ShouldEqual, "a.pshdl:2:10: warning: spread over lines")
+		})
+
+		Convey("should point problems without location at the start of the file", func() {
+			p.Location.Line = 0
+			p.Location.OffsetInLine = 0
+			p.ErrorCode = "E1"
+			So(Line("a.pshdl", p), ShouldEqual, "a.pshdl:1:1: warning: spread over lines [E1]")
+		})
+	})
+}
+
+// quickfixEntry is what an editor shows for a problem
+type quickfixEntry struct {
+	file      string
+	line, col int
+	kind      string
+	text      string
+}
+
+// vimQuickfix reads output like Vim does with errorformat efm. It knows
+// the items of efm that VimErrorformat uses.
+func vimQuickfix(efm, output string) []quickfixEntry {
+	type pattern struct {
+		re     *regexp.Regexp
+		prefix string // E, W, I, +C or -G
+	}
+
+	var patterns []pattern
+	for _, item := range strings.Split(efm, ",") {
+		var p pattern
+		for _, prefix := range []string{"%E", "%W", "%I", "%+C", "%-G"} {
+			if strings.HasPrefix(item, prefix) {
+				p.prefix = prefix[1:]
+				item = item[len(prefix):]
+			}
+		}
+		re := regexp.QuoteMeta(item)
+		for from, to := range map[string]string{"%f": `(?P<f>.+?)`, "%l": `(?P<l>\d+)`, "%c": `(?P<c>\d+)`, "%m": `(?P<m>.*)`, "%.%#": `.*`} {
+			re = strings.Replace(re, regexp.QuoteMeta(from), to, -1)
+		}
+		p.re = regexp.MustCompile("^" + re + "$")
+		patterns = append(patterns, p)
+	}
+
+	var entries []quickfixEntry
+	for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+		for _, p := range patterns {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			group := func(name string) string { return m[p.re.SubexpIndex(name)] }
+
+			switch p.prefix {
+			case "E", "W", "I":
+				e := quickfixEntry{file: group("f"), kind: p.prefix, text: group("m")}
+				e.line, _ = strconv.Atoi(group("l"))
+				e.col, _ = strconv.Atoi(group("c"))
+				entries = append(entries, e)
+			case "+C":
+				if len(entries) > 0 {
+					entries[len(entries)-1].text += "\n" + line
+				}
+			}
+			break
+		}
+	}
+	return entries
+}
+
+// emacsGnu is the part of the gnu rule of Emacs compilation-mode that matches our lines
+var emacsGnu = regexp.MustCompile(`^([^ \t\n:][^\n:]*):([0-9]+):([0-9]+): (?:(warning)|(info)|error):`)
+
+func TestEditors(t *testing.T) {
+	Convey("Given the output of Write()", t, func() {
+		var buf bytes.Buffer
+		So(Write(&buf, loadWorkspace(), Options{Explanation: true, Solutions: true}), ShouldBeNil)
+		output := buf.String()
+
+		Convey("Vim should find every problem with its advice", func() {
+			entries := vimQuickfix(VimErrorformat, output)
+			So(entries, ShouldHaveLength, 3)
+
+			So(entries[0].file, ShouldEqual, "a.pshdl")
+			So(entries[0].line, ShouldEqual, 1)
+			So(entries[0].kind, ShouldEqual, "I")
+
+			So(entries[1].file, ShouldEqual, "a.pshdl")
+			So(entries[1].line, ShouldEqual, 3)
+			So(entries[1].col, ShouldEqual, 5)
+			So(entries[1].kind, ShouldEqual, "E")
+			So(entries[1].text, ShouldStartWith, "The reference y can not be resolved [UNRESOLVED_REFERENCE]\n")
+			So(entries[1].text, ShouldContainSubstring, "solution: Check the spelling of y")
+
+			So(entries[2].file, ShouldEqual, "pkg/b.pshdl")
+			So(entries[2].line, ShouldEqual, 7)
+			So(entries[2].kind, ShouldEqual, "W")
+		})
+
+		Convey("Emacs should match the problem lines and nothing else", func() {
+			var matched []string
+			for _, line := range strings.Split(strings.TrimSuffix(output, "\n"), "\n") {
+				if m := emacsGnu.FindStringSubmatch(line); m != nil {
+					matched = append(matched, m[1]+":"+m[2]+":"+m[3])
+				}
+			}
+			So(matched, ShouldResemble, []string{"a.pshdl:1:1", "a.pshdl:3:5", "pkg/b.pshdl:7:1"})
+		})
+	})
+}
@@ -0,0 +1,433 @@
+// Package recorder provides an http.RoundTripper that records interactions
+// with the PSHDL REST API into a cassette file and replays them later.
+//
+// Use it with pshdlApi.WithHTTPClient(rec.Client()) to run code against a
+// recorded session without network access. Event streams are recorded as
+// they are read, so a replayed stream ends where the recording stopped.
+package recorder
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Mode tells the Recorder what to do with requests
+type Mode int
+
+const (
+	// ModeReplay serves responses from the cassette and never touches the network
+	ModeReplay Mode = iota
+	// ModeRecord sends requests to the server and stores the interactions in the cassette
+	ModeRecord
+)
+
+func (m Mode) String() string {
+	switch m {
+	case ModeReplay:
+		return "replay"
+	case ModeRecord:
+		return "record"
+	}
+	return fmt.Sprintf("Mode(%d)", int(m))
+}
+
+// ErrNoInteraction is returned in replay mode if the cassette has no
+// unused interaction matching a request
+var ErrNoInteraction = errors.New("recorder: no matching interaction")
+
+// Request is a recorded HTTP request
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Response is a recorded HTTP response
+type Response struct {
+	StatusCode int           `json:"statusCode"`
+	Header     http.Header   `json:"header,omitempty"`
+	Body       Body          `json:"body,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Interaction is a request and the response the server sent for it
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+// Body holds recorded bytes. It is stored as a plain JSON string if it is
+// valid UTF-8, which keeps cassettes readable, and base64 encoded otherwise.
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+
+	var enc struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &enc); err != nil {
+		return err
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(enc.Base64)
+	if err != nil {
+		return err
+	}
+	*b = raw
+	return nil
+}
+
+// Cassette is the list of interactions stored in a cassette file
+type Cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// A Matcher reports if the recorded interaction i answers req.
+// body is the already read body of req.
+type Matcher func(req *http.Request, body []byte, i *Interaction) bool
+
+// DefaultMatcher matches on method and URL. Bodies are ignored since
+// multipart uploads use random boundaries.
+func DefaultMatcher(req *http.Request, body []byte, i *Interaction) bool {
+	return req.Method == i.Request.Method && req.URL.String() == i.Request.URL
+}
+
+// BodyMatcher matches like DefaultMatcher and also compares the bodies
+func BodyMatcher(req *http.Request, body []byte, i *Interaction) bool {
+	return DefaultMatcher(req, body, i) && bytes.Equal(body, i.Request.Body)
+}
+
+// Recorder is an http.RoundTripper that records or replays interactions
+type Recorder struct {
+	// Matcher selects the interaction to replay, DefaultMatcher if nil
+	Matcher Matcher
+
+	mode Mode
+	path string
+	next http.RoundTripper
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+	open     map[*recordingBody]struct{}
+	err      error // first error saving the cassette
+
+	// saveMu serializes writing the cassette file
+	saveMu sync.Mutex
+}
+
+// autosaveInterval limits how often a streamed response saves the cassette
+const autosaveInterval = time.Second
+
+// New returns a Recorder for the cassette at path.
+// In ModeReplay the cassette is loaded from path, in ModeRecord an existing
+// cassette is overwritten and requests are sent using http.DefaultTransport.
+func New(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{
+		mode: mode,
+		path: path,
+		next: http.DefaultTransport,
+		open: make(map[*recordingBody]struct{}),
+	}
+
+	switch mode {
+	case ModeReplay:
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("recorder: invalid cassette %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+
+	case ModeRecord:
+		if err := r.save(); err != nil {
+			return nil, err
+		}
+
+	default:
+		return nil, fmt.Errorf("recorder: unknown %s", mode)
+	}
+
+	return r, nil
+}
+
+// SetTransport sets the RoundTripper used to reach the server in ModeRecord
+func (r *Recorder) SetTransport(rt http.RoundTripper) {
+	r.next = rt
+}
+
+// Mode returns the mode of the recorder
+func (r *Recorder) Mode() Mode {
+	return r.mode
+}
+
+// Client returns an http.Client that uses the recorder as its transport
+func (r *Recorder) Client() *http.Client {
+	return &http.Client{Transport: r}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if r.mode == ModeReplay {
+		return r.replay(req, body)
+	}
+	return r.record(req, body)
+}
+
+// Stop finishes the recording of responses that are still being read,
+// like open event streams, and writes the cassette. It returns the first
+// error of an earlier automatic save or of the final one.
+// It is a no-op in ModeReplay.
+func (r *Recorder) Stop() error {
+	if r.mode != ModeRecord {
+		return nil
+	}
+
+	r.mu.Lock()
+	for b := range r.open {
+		b.finish()
+	}
+	r.open = make(map[*recordingBody]struct{})
+	r.mu.Unlock()
+
+	saveErr := r.save()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return saveErr
+}
+
+func (r *Recorder) replay(req *http.Request, body []byte) (*http.Response, error) {
+	match := r.Matcher
+	if match == nil {
+		match = DefaultMatcher
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for idx, i := range r.cassette.Interactions {
+		if r.used[idx] || !match(req, body, i) {
+			continue
+		}
+		r.used[idx] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", i.Response.StatusCode, http.StatusText(i.Response.StatusCode)),
+			StatusCode:    i.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        i.Response.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(i.Response.Body)),
+			ContentLength: int64(len(i.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w for %s %s", ErrNoInteraction, req.Method, req.URL)
+}
+
+func (r *Recorder) record(req *http.Request, body []byte) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if body != nil {
+		out.Body = ioutil.NopCloser(bytes.NewReader(body))
+		out.ContentLength = int64(len(body))
+	}
+
+	start := time.Now()
+	resp, err := r.next.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+
+	i := &Interaction{
+		Request: Request{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: req.Header.Clone(),
+			Body:   body,
+		},
+		Response: Response{
+			StatusCode: resp.StatusCode,
+			Header:     resp.Header.Clone(),
+			Duration:   time.Since(start),
+		},
+	}
+
+	rb := &recordingBody{
+		rec:       r,
+		i:         i,
+		body:      resp.Body,
+		streaming: strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream"),
+	}
+
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, i)
+	r.open[rb] = struct{}{}
+	r.mu.Unlock()
+
+	resp.Body = rb
+	return resp, nil
+}
+
+// save writes the cassette to disk, with what was read so far of the
+// bodies that are still open. The file is replaced atomically.
+func (r *Recorder) save() error {
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	for b := range r.open {
+		b.i.Response.Body = b.buf.Bytes()
+	}
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	for b := range r.open {
+		b.i.Response.Body = nil
+	}
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(r.path), filepath.Base(r.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, r.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// autosave saves the cassette and keeps the first error for Stop
+func (r *Recorder) autosave() {
+	if err := r.save(); err != nil {
+		r.mu.Lock()
+		if r.err == nil {
+			r.err = err
+		}
+		r.mu.Unlock()
+	}
+}
+
+// recordingBody copies everything read from a response body into its interaction
+type recordingBody struct {
+	rec       *Recorder
+	i         *Interaction
+	body      io.ReadCloser
+	streaming bool
+
+	// buf, done and lastSave are guarded by rec.mu
+	buf      bytes.Buffer
+	done     bool
+	lastSave time.Time
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 {
+		b.rec.mu.Lock()
+		save := false
+		if !b.done {
+			b.buf.Write(p[:n])
+
+			// streams might never end, so store what we have every now and then
+			if b.streaming && time.Since(b.lastSave) >= autosaveInterval {
+				b.lastSave = time.Now()
+				save = true
+			}
+		}
+		b.rec.mu.Unlock()
+
+		if save {
+			b.rec.autosave()
+		}
+	}
+
+	if err == io.EOF {
+		b.complete()
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	err := b.body.Close()
+	b.complete()
+	return err
+}
+
+// finish stores the body read so far in the interaction, rec.mu must be held
+func (b *recordingBody) finish() {
+	b.done = true
+	b.i.Response.Body = append(Body(nil), b.buf.Bytes()...)
+	b.buf = bytes.Buffer{}
+}
+
+// complete removes b from the open bodies and saves the cassette
+func (b *recordingBody) complete() {
+	b.rec.mu.Lock()
+	if b.done {
+		b.rec.mu.Unlock()
+		return
+	}
+	b.finish()
+	delete(b.rec.open, b)
+	b.rec.mu.Unlock()
+
+	b.rec.autosave()
+}
@@ -0,0 +1,188 @@
+package recorder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/cryptix/goPshdlRest/api"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `{"id":"1234","validated":true}`)
+		case "POST":
+			w.WriteHeader(http.StatusOK)
+		}
+	})
+	mux.HandleFunc("/api/v0.1/workspace/1234/bin.dat", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte{0xff, 0xfe, 0x00, 0x01})
+	})
+	mux.HandleFunc("/api/v0.1/streaming/workspace/1234/clientID", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "c1")
+	})
+	mux.HandleFunc("/api/v0.1/streaming/workspace/1234/c1/sse", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"subject\":\"P:PING\"}\n\n")
+		fmt.Fprint(w, "data: {\"subject\":\"P:WORKSPACE:ADDED\",\"contents\":[{\"record\":{\"relPath\":\"a.pshdl\"}}]}\n\n")
+	})
+	return httptest.NewServer(mux)
+}
+
+// session runs a few typical calls against the API at baseURL using hc
+func session(baseURL string, hc *http.Client) (subjects []string, err error) {
+	ctx := context.Background()
+
+	c, err := pshdlApi.NewClient(
+		pshdlApi.WithBaseURL(baseURL),
+		pshdlApi.WithHTTPClient(hc),
+		pshdlApi.WithWorkspaceID("1234"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	wp, _, err := c.Workspace.GetInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !wp.Validated {
+		return nil, errors.New("workspace not validated")
+	}
+
+	if err = c.Workspace.UploadFile(ctx, "a.pshdl", strings.NewReader("module a {}")); err != nil {
+		return nil, err
+	}
+
+	events, err := c.Streaming.OpenEventStream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for ev := range events {
+		subjects = append(subjects, ev.GetSubject())
+	}
+
+	return subjects, nil
+}
+
+func TestRecorder(t *testing.T) {
+	Convey("Given a temporary cassette", t, func() {
+		dir, err := ioutil.TempDir("", "recorder")
+		So(err, ShouldBeNil)
+		cassette := filepath.Join(dir, "session.json")
+
+		Convey("a recorded session should replay without the server", func() {
+			srv := newTestServer()
+			baseURL := srv.URL + "/api/v0.1/"
+
+			rec, err := New(cassette, ModeRecord)
+			So(err, ShouldBeNil)
+
+			want, err := session(baseURL, rec.Client())
+			So(err, ShouldBeNil)
+			So(want, ShouldResemble, []string{"P:PING", "P:WORKSPACE:ADDED"})
+			So(rec.Stop(), ShouldBeNil)
+
+			srv.Close()
+
+			rec, err = New(cassette, ModeReplay)
+			So(err, ShouldBeNil)
+
+			got, err := session(baseURL, rec.Client())
+			So(err, ShouldBeNil)
+			So(got, ShouldResemble, want)
+
+			Convey("and every interaction is only used once", func() {
+				_, err := session(baseURL, rec.Client())
+				So(errors.Is(err, ErrNoInteraction), ShouldBeTrue)
+			})
+		})
+
+		Convey("binary bodies should survive the cassette", func() {
+			srv := newTestServer()
+			defer srv.Close()
+
+			rec, err := New(cassette, ModeRecord)
+			So(err, ShouldBeNil)
+
+			resp, err := rec.Client().Get(srv.URL + "/api/v0.1/workspace/1234/bin.dat")
+			So(err, ShouldBeNil)
+			want, _ := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			So(rec.Stop(), ShouldBeNil)
+
+			rec, err = New(cassette, ModeReplay)
+			So(err, ShouldBeNil)
+
+			resp, err = rec.Client().Get(srv.URL + "/api/v0.1/workspace/1234/bin.dat")
+			So(err, ShouldBeNil)
+			got, _ := ioutil.ReadAll(resp.Body)
+			So(got, ShouldResemble, want)
+		})
+
+		Convey("concurrent responses should all end up in the cassette", func() {
+			srv := newTestServer()
+			defer srv.Close()
+
+			rec, err := New(cassette, ModeRecord)
+			So(err, ShouldBeNil)
+
+			const n = 16
+			var wg sync.WaitGroup
+			errs := make(chan error, n)
+			for i := 0; i < n; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					resp, err := rec.Client().Get(srv.URL + "/api/v0.1/workspace/1234/bin.dat")
+					if err != nil {
+						errs <- err
+						return
+					}
+					ioutil.ReadAll(resp.Body)
+					resp.Body.Close()
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				So(err, ShouldBeNil)
+			}
+			So(rec.Stop(), ShouldBeNil)
+
+			data, err := ioutil.ReadFile(cassette)
+			So(err, ShouldBeNil)
+			var c Cassette
+			So(json.Unmarshal(data, &c), ShouldBeNil)
+			So(c.Interactions, ShouldHaveLength, n)
+			for _, i := range c.Interactions {
+				So(i.Response.Body, ShouldResemble, Body{0xff, 0xfe, 0x00, 0x01})
+			}
+
+			tmps, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+			So(tmps, ShouldBeEmpty)
+		})
+
+		Convey("a missing cassette should fail in replay mode", func() {
+			_, err := New(filepath.Join(dir, "nope.json"), ModeReplay)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+
+		Reset(func() {
+			os.RemoveAll(dir)
+		})
+	})
+}
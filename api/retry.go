@@ -0,0 +1,181 @@
+package pshdlApi
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes if and how failed requests are retried.
+// Network errors and the status codes 429, 500, 502, 503 and 504 count as failures.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. 0 disables retrying.
+	MaxRetries int
+
+	// MinBackoff is the wait before the first retry, it doubles with each
+	// further retry up to MaxBackoff. A Retry-After header sent by the server
+	// takes precedence, but is limited to MaxBackoff as well.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	// RetryNonIdempotent also retries POST requests.
+	// Only enable this if creating things twice is acceptable.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy retries idempotent requests three times
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 250 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// WithRetryPolicy sets the policy for retrying failed requests.
+// By default requests are not retried.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retry = p
+		return nil
+	}
+}
+
+// roundTrip sends req bound to ctx and retries it according to the retry policy of the client
+func (c *Client) roundTrip(ctx context.Context, req *http.Request) (*http.Response, error) {
+	req = req.WithContext(ctx)
+	send := c.chain()
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := send(req)
+		if !c.retry.shouldRetry(req, resp, err, attempt) {
+			return resp, err
+		}
+
+		wait := c.retry.backoff(attempt, resp)
+		if err != nil {
+			c.logger.Debug("retrying request", "method", req.Method, "path", req.URL.Path, "attempt", attempt+1, "wait", wait, "err", err)
+		} else {
+			c.logger.Debug("retrying request", "method", req.Method, "path", req.URL.Path, "attempt", attempt+1, "wait", wait, "status", resp.StatusCode)
+			// drain so the connection can be reused
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// shouldRetry decides if req is sent again after the attempt that resulted in resp or err
+func (p RetryPolicy) shouldRetry(req *http.Request, resp *http.Response, err error, attempt int) bool {
+	if attempt >= p.MaxRetries {
+		return false
+	}
+
+	if err != nil && req.Context().Err() != nil {
+		return false
+	}
+
+	switch req.Method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+	default:
+		if !p.RetryNonIdempotent {
+			return false
+		}
+	}
+
+	// the body was consumed and can't be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns how long to wait before the next attempt
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if d, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			if p.MaxBackoff > 0 && d > p.MaxBackoff {
+				d = p.MaxBackoff
+			}
+			return d
+		}
+	}
+
+	// double without overflowing, a shift by attempt could wrap around
+	d := p.MinBackoff
+	for i := 0; i < attempt && d > 0 && d <= math.MaxInt64/2; i++ {
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			break
+		}
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	// jitter between d/2 and d
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+// parseRetryAfter parses the value of a Retry-After header,
+// which is either in seconds or a HTTP date
+func parseRetryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if int64(secs) > math.MaxInt64/int64(time.Second) {
+			return math.MaxInt64, true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	t, err := http.ParseTime(v)
+	if err != nil {
+		return 0, false
+	}
+
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
@@ -0,0 +1,181 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+var testRetryPolicy = RetryPolicy{
+	MaxRetries: 2,
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 5 * time.Millisecond,
+}
+
+func TestRetryPolicy(t *testing.T) {
+	Convey("Given a test server and a client with a retry policy", t, func() {
+		setup()
+		client.retry = testRetryPolicy
+
+		var attempts int
+
+		Convey("a GET that fails twice should succeed on the third attempt", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts < 3 {
+					http.Error(w, "try again", http.StatusServiceUnavailable)
+					return
+				}
+				fmt.Fprint(w, `{"id":"1234"}`)
+			})
+
+			wp, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(wp.ID, ShouldEqual, "1234")
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("a GET should give up after MaxRetries", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				http.Error(w, "down", http.StatusBadGateway)
+			})
+
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldNotBeNil)
+			So(attempts, ShouldEqual, 3)
+		})
+
+		Convey("a client error should not be retried", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234/a.pshdl", func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				http.Error(w, "nope", http.StatusBadRequest)
+			})
+
+			_, _, err := client.Workspace.Delete(context.Background(), "a.pshdl")
+			So(err, ShouldNotBeNil)
+			So(attempts, ShouldEqual, 1)
+		})
+
+		Convey("UploadFile()", func() {
+			var bodies []string
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				f, _, err := r.FormFile("file")
+				if err == nil {
+					data, _ := ioutil.ReadAll(f)
+					bodies = append(bodies, string(data))
+				}
+				if attempts == 1 {
+					http.Error(w, "busy", http.StatusServiceUnavailable)
+					return
+				}
+			})
+
+			Convey("should not be retried by default", func() {
+				err := client.Workspace.UploadFile(context.Background(), "a.pshdl", bytes.NewReader([]byte("module a {}")))
+				So(err, ShouldNotBeNil)
+				So(attempts, ShouldEqual, 1)
+			})
+
+			Convey("should resend the whole body when opted in", func() {
+				client.retry.RetryNonIdempotent = true
+
+				err := client.Workspace.UploadFile(context.Background(), "a.pshdl", bytes.NewReader([]byte("module a {}")))
+				So(err, ShouldBeNil)
+				So(attempts, ShouldEqual, 2)
+				So(bodies, ShouldResemble, []string{"module a {}", "module a {}"})
+			})
+		})
+
+		Convey("Retry-After should be honoured", func() {
+			client.retry.MaxBackoff = 2 * time.Second
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				attempts++
+				if attempts == 1 {
+					w.Header().Set("Retry-After", "1")
+					http.Error(w, "slow down", http.StatusTooManyRequests)
+					return
+				}
+				fmt.Fprint(w, `{"id":"1234"}`)
+			})
+
+			start := time.Now()
+			_, _, err := client.Workspace.GetInfo(context.Background())
+			So(err, ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, time.Second)
+		})
+
+		Convey("waiting for a retry should stop when ctx is done", func() {
+			client.retry.MinBackoff = time.Minute
+			client.retry.MaxBackoff = time.Minute
+			mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "down", http.StatusServiceUnavailable)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			_, _, err := client.Workspace.GetInfo(ctx)
+			So(err, ShouldEqual, context.DeadlineExceeded)
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestBackoff(t *testing.T) {
+	Convey("backoff()", t, func() {
+		p := RetryPolicy{MaxRetries: 100, MinBackoff: time.Second, MaxBackoff: 10 * time.Second}
+
+		Convey("should limit Retry-After to MaxBackoff", func() {
+			resp := &http.Response{Header: http.Header{"Retry-After": {"3600"}}}
+			So(p.backoff(0, resp), ShouldEqual, 10*time.Second)
+
+			resp.Header.Set("Retry-After", "99999999999999")
+			So(p.backoff(0, resp), ShouldEqual, 10*time.Second)
+
+			resp.Header.Set("Retry-After", "2")
+			So(p.backoff(0, resp), ShouldEqual, 2*time.Second)
+		})
+
+		Convey("should stay between MaxBackoff/2 and MaxBackoff for many attempts", func() {
+			for _, attempt := range []int{4, 33, 63, 64, 100} {
+				d := p.backoff(attempt, nil)
+				So(d, ShouldBeBetweenOrEqual, 5*time.Second, 10*time.Second)
+			}
+		})
+
+		Convey("should not overflow without MaxBackoff", func() {
+			p.MaxBackoff = 0
+			for _, attempt := range []int{34, 63, 64, 100} {
+				So(p.backoff(attempt, nil), ShouldBeGreaterThan, time.Duration(math.MaxInt64/4))
+			}
+		})
+	})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	Convey("parseRetryAfter()", t, func() {
+		d, ok := parseRetryAfter("3")
+		So(ok, ShouldBeTrue)
+		So(d, ShouldEqual, 3*time.Second)
+
+		d, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+		So(ok, ShouldBeTrue)
+		So(d, ShouldBeGreaterThan, 59*time.Minute)
+
+		_, ok = parseRetryAfter("")
+		So(ok, ShouldBeFalse)
+
+		_, ok = parseRetryAfter("soon")
+		So(ok, ShouldBeFalse)
+	})
+}
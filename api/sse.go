@@ -0,0 +1,76 @@
+package pshdlApi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+)
+
+// sseEvent is a single event read from a text/event-stream
+type sseEvent struct {
+	ID    string
+	Event string
+	Data  []byte
+}
+
+// readSSE parses the server-sent events in r and sends them on events.
+// It returns when r is exhausted, reading from it failed or ctx is done.
+func readSSE(ctx context.Context, r io.Reader, events chan<- sseEvent) error {
+	var (
+		ev      sseEvent
+		data    bytes.Buffer
+		scanner = bufio.NewScanner(r)
+	)
+
+	// compiler events can get quite big
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+
+	for scanner.Scan() {
+		line := scanner.Bytes()
+
+		// an empty line dispatches the event
+		if len(line) == 0 {
+			if data.Len() == 0 {
+				ev = sseEvent{}
+				continue
+			}
+
+			ev.Data = bytes.TrimSuffix(append([]byte(nil), data.Bytes()...), []byte("\n"))
+			select {
+			case events <- ev:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+
+			ev = sseEvent{}
+			data.Reset()
+			continue
+		}
+
+		// comment
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], bytes.TrimPrefix(line[i+1:], []byte(" "))
+		}
+
+		switch string(field) {
+		case "data":
+			data.Write(value)
+			data.WriteByte('\n')
+		case "event":
+			ev.Event = string(value)
+		case "id":
+			ev.ID = string(value)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return scanner.Err()
+}
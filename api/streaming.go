@@ -2,22 +2,38 @@ package pshdlApi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"os"
 	"time"
-
-	"github.com/cryptix/goSSEClient"
 )
 
 // StreamingService handles communication with the streaming related
-// methods of the PsHdl REST API.
+// methods of the PsHdl REST API for the workspace with ID.
+// Use Client.OpenWorkspace to work with several workspaces at once.
 type StreamingService struct {
 	// wrapped http client
 	client *Client
 	// current workspace Id
-	ID       string
-	clientID string
+	ID string
+
+	// handle of the last opened stream, it knows the client ID
+	h *WorkspaceHandle
+}
+
+// OpenEventStream connects to the event stream of the workspace, see WorkspaceHandle.OpenEventStream
+func (s *StreamingService) OpenEventStream(ctx context.Context) (<-chan StreamingEvent, error) {
+	s.h = s.client.OpenWorkspace(s.ID)
+	return s.h.OpenEventStream(ctx)
+}
+
+// SendClientConnected tells the API that the client of the last opened
+// event stream is connected
+func (s *StreamingService) SendClientConnected(ctx context.Context) error {
+	if s.h == nil || s.h.id != s.ID {
+		s.h = s.client.OpenWorkspace(s.ID)
+	}
+	return s.h.SendClientConnected(ctx)
 }
 
 type StreamingEvent interface {
@@ -25,34 +41,60 @@ type StreamingEvent interface {
 	GetFiles() []Record
 }
 
-func (s *StreamingService) OpenEventStream() (<-chan StreamingEvent, error) {
-	req, err := s.client.NewRequest("GET", fmt.Sprintf("streaming/workspace/%s/clientID", s.ID), nil)
+// OpenEventStream connects to the event stream of the workspace.
+// The returned channel is closed when the stream ends or ctx is done.
+func (h *WorkspaceHandle) OpenEventStream(ctx context.Context) (<-chan StreamingEvent, error) {
+	ctx = withOperation(ctx, "Streaming", "OpenEventStream")
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
+
+	req, err := h.client.NewRequest("GET", fmt.Sprintf("streaming/workspace/%s/clientID", h.id), nil)
 	if err != nil {
 		return nil, err
 	}
 
-	cid, _, err := s.client.DoPlain(req)
+	cid, _, err := h.client.DoPlain(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	s.clientID = string(cid)
-	dbg("OpenEventStream() Client ID:%s", s.clientID)
+	clientID := string(cid)
+	h.setClientID(clientID)
+	h.client.logger.Debug("event stream client", "workspace", h.id, "clientID", clientID)
 
-	req, err = s.client.NewRequest("GET", fmt.Sprintf("streaming/workspace/%s/%s/sse", s.ID, s.clientID), nil)
+	req, err = h.client.NewRequest("GET", fmt.Sprintf("streaming/workspace/%s/%s/sse", h.id, clientID), nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept", "text/event-stream")
 
-	sseEvent, err := goSSEClient.OpenSSEUrl(req.URL.String())
+	resp, err := h.client.roundTrip(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	dbg("OpenEventStream sseEvent channel open")
+
+	if err = CheckResponse(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	h.client.logger.Debug("event stream open", "workspace", h.id, "clientID", clientID)
+
+	rawEvents := make(chan sseEvent)
+	go func() {
+		defer resp.Body.Close()
+		defer close(rawEvents)
+
+		if err := readSSE(ctx, resp.Body, rawEvents); err != nil {
+			h.client.logger.Debug("event stream failed", "workspace", h.id, "err", err)
+		}
+	}()
 
 	events := make(chan StreamingEvent)
 
 	go func() {
-		for ev := range sseEvent {
+		defer close(events)
+
+		for ev := range rawEvents {
 
 			var peek struct {
 				Subject string
@@ -61,8 +103,8 @@ func (s *StreamingService) OpenEventStream() (<-chan StreamingEvent, error) {
 
 			err := json.Unmarshal(ev.Data, &peek)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error during Peek Unmarshal:%s\n", err)
-				close(events)
+				h.client.logger.Warn("could not decode event", "workspace", h.id, "err", err)
+				continue
 			}
 
 			var apiEvent StreamingEvent
@@ -88,23 +130,28 @@ func (s *StreamingService) OpenEventStream() (<-chan StreamingEvent, error) {
 				apiEvent = new(PingEvent)
 
 			default:
-				fmt.Fprintf(os.Stderr, "Error unhandeld event type!:%v\n", peek)
+				h.client.logger.Warn("unhandled event", "workspace", h.id, "subject", peek.Subject, "msgType", peek.MsgType)
 				continue
 			}
 
-			dbg("ssEvent: %s", peek.Subject)
+			h.client.logger.Debug("event", "workspace", h.id, "subject", peek.Subject)
 
 			err = json.Unmarshal(ev.Data, &apiEvent)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "Error during Event Unmarshal:%s\ndata:%s\n", err, ev.Data)
-				close(events)
-			} else {
-				events <- apiEvent
+				h.client.logger.Warn("could not decode event", "workspace", h.id, "subject", peek.Subject, "err", err, "data", string(ev.Data))
+				continue
 			}
 
+			select {
+			case events <- apiEvent:
+			case <-ctx.Done():
+				// drain so the reader can notice ctx and exit
+				for range rawEvents {
+				}
+				return
+			}
 		}
-		fmt.Fprintln(os.Stderr, "SSEvent chan was closed.")
-		close(events)
+		h.client.logger.Debug("event stream closed", "workspace", h.id)
 	}()
 
 	return events, nil
@@ -117,11 +164,22 @@ type StreamingClientEvent struct {
 	Subject   string `json:"subject"`
 }
 
-func (s *StreamingService) SendClientConnected() error {
-	dbg("Streaming.SendClientConnected(%s) Client:%s", s.ID, s.clientID)
+// SendClientConnected tells the API that the client of the event stream
+// last opened with h is connected
+func (h *WorkspaceHandle) SendClientConnected(ctx context.Context) error {
+	ctx = withOperation(ctx, "Streaming", "SendClientConnected")
+	if h.id == "" {
+		return ErrNoWorkspaceID
+	}
+
+	clientID := h.getClientID()
+	if clientID == "" {
+		return ErrNoEventStream
+	}
+	h.client.logger.Debug("send client connected", "workspace", h.id, "clientID", clientID)
 
 	body, err := json.Marshal(StreamingClientEvent{
-		ID:        s.clientID,
+		ID:        clientID,
 		Timestamp: time.Now().Unix(),
 		Subject:   "P:CLIENT:CONNECTED",
 	})
@@ -129,9 +187,9 @@ func (s *StreamingService) SendClientConnected() error {
 		return err
 	}
 
-	req, err := s.client.NewReaderRequest(
+	req, err := h.client.NewReaderRequest(
 		"POST",
-		fmt.Sprintf("streaming/workspace/%s/%s", s.ID, s.clientID),
+		fmt.Sprintf("streaming/workspace/%s/%s", h.id, clientID),
 		bytes.NewReader(body),
 		"application/json",
 	)
@@ -139,14 +197,10 @@ func (s *StreamingService) SendClientConnected() error {
 		return err
 	}
 
-	resp, err := s.client.Do(req, nil)
+	resp, err := h.client.Do(ctx, req, nil)
 	if err != nil {
-		return nil
-	}
-
-	if err = CheckResponse(resp); err != nil {
 		return err
 	}
 
-	return nil
+	return resp.Body.Close()
 }
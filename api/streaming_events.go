@@ -1,9 +1,6 @@
 package pshdlApi
 
-import (
-	"fmt"
-	"os"
-)
+import "context"
 
 type PshdlEventMetaInfo struct {
 	Subject   string
@@ -30,9 +27,9 @@ func (ev *WorskpaceUpdatedEvent) GetFiles() []Record {
 	return files
 }
 
-func (ev *WorskpaceUpdatedEvent) DownloadFiles(ws WorkspaceService) error {
-	fmt.Fprintln(os.Stderr, "[!] Download not support for WorskpaceUpdatedEvent.")
-	return nil
+// DownloadFiles downloads the updated files of the event
+func (ev *WorskpaceUpdatedEvent) DownloadFiles(ctx context.Context, ws *WorkspaceService) error {
+	return ws.DownloadRecords(ctx, ev.GetFiles(), DownloadOptions{})
 }
 
 // P:WORKSPACE:DELETED
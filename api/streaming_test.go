@@ -0,0 +1,83 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestStreamingService(t *testing.T) {
+	Convey("Given a clean test server for the StreamingService", t, func() {
+		setup()
+		client.Streaming.ID = "1234"
+
+		mux.HandleFunc("/api/v0.1/streaming/workspace/1234/clientID", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "c1")
+		})
+
+		// hold keeps the sse handler open until the test is done
+		hold := make(chan struct{})
+
+		mux.HandleFunc("/api/v0.1/streaming/workspace/1234/c1/sse", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			fmt.Fprint(w, ": hello\n\n")
+			fmt.Fprint(w, "data: {\"subject\":\"P:PING\"}\n\n")
+			fmt.Fprint(w, "data: {\"subject\":\"P:WORKSPACE:UPDATED\",\n")
+			fmt.Fprint(w, "data: \"contents\":[{\"record\":{\"relPath\":\"a.pshdl\"}}]}\n\n")
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-hold:
+			case <-r.Context().Done():
+			}
+		})
+
+		Convey("OpenEventStream() should decode the events", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			events, err := client.Streaming.OpenEventStream(ctx)
+			So(err, ShouldBeNil)
+
+			ev := <-events
+			So(ev.GetSubject(), ShouldEqual, "P:PING")
+
+			ev = <-events
+			So(ev.GetSubject(), ShouldEqual, "P:WORKSPACE:UPDATED")
+			So(ev.GetFiles(), ShouldResemble, []Record{{RelPath: "a.pshdl"}})
+		})
+
+		Convey("OpenEventStream() should close the channel when ctx is canceled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+
+			events, err := client.Streaming.OpenEventStream(ctx)
+			So(err, ShouldBeNil)
+			cancel()
+
+			closed := false
+			timeout := time.After(time.Second)
+		loop:
+			for {
+				select {
+				case _, ok := <-events:
+					if !ok {
+						closed = true
+						break loop
+					}
+				case <-timeout:
+					break loop
+				}
+			}
+			So(closed, ShouldBeTrue)
+		})
+
+		Reset(func() {
+			close(hold)
+			teardown()
+		})
+	})
+}
@@ -0,0 +1,263 @@
+package pshdlApi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+)
+
+// how the API takes uploads of several files, learned with the first UploadFiles
+const (
+	uploadUnknown int32 = iota
+	uploadMulti
+	uploadSingle
+)
+
+// uploadBufferSize is how much of a reader that can't seek is buffered in
+// memory, larger content is spooled to a temporary file
+const uploadBufferSize = 1 << 20
+
+// UploadFile adds a file with fname to the workspace.
+// The content of fbuf is streamed to the API. If fbuf can't seek, it is
+// buffered first so the request can be sent again, in memory up to 1 MiB
+// and in a temporary file above that.
+func (h *WorkspaceHandle) UploadFile(ctx context.Context, fname string, fbuf io.Reader) error {
+	ctx = withOperation(ctx, "Workspace", "UploadFile")
+	h.client.logger.Debug("upload file", "workspace", h.id, "path", fname)
+
+	if h.id == "" {
+		return ErrNoWorkspaceID
+	}
+
+	files, cleanup, err := rewindable(map[string]io.Reader{fname: fbuf})
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	offs, err := readerOffsets(files)
+	if err != nil {
+		return err
+	}
+	return h.upload(ctx, []string{fname}, files, offs)
+}
+
+// UploadFiles adds all files to the workspace, the keys are the file names.
+// The files are streamed to the API as the parts of one multipart request,
+// in the order of their names. Readers that can't seek are buffered like in
+// UploadFile. If the API answers that it doesn't support several files in
+// one request, the files are sent one per request instead. Once that
+// succeeded, the client sends single files from then on.
+func (h *WorkspaceHandle) UploadFiles(ctx context.Context, files map[string]io.Reader) error {
+	ctx = withOperation(ctx, "Workspace", "UploadFiles")
+	h.client.logger.Debug("upload files", "workspace", h.id, "files", len(files))
+
+	if h.id == "" {
+		return ErrNoWorkspaceID
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	files, cleanup, err := rewindable(files)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	offs, err := readerOffsets(files)
+	if err != nil {
+		return err
+	}
+
+	mode := &h.client.uploadMode
+	fallback := false
+	if len(names) > 1 && atomic.LoadInt32(mode) != uploadSingle {
+		err := h.upload(ctx, names, files, offs)
+		if !multiUploadRejected(err) {
+			if err == nil {
+				atomic.StoreInt32(mode, uploadMulti)
+			}
+			return err
+		}
+
+		h.client.logger.Info("multi-file upload rejected, uploading single files", "workspace", h.id, "err", err)
+		if err := seekReaders(files, offs); err != nil {
+			return err
+		}
+		fallback = true
+	}
+
+	for _, name := range names {
+		err := h.upload(ctx, []string{name}, files, offs)
+		if err != nil {
+			return fmt.Errorf("upload of %s failed: %w", name, err)
+		}
+	}
+
+	if fallback {
+		atomic.StoreInt32(mode, uploadSingle)
+	}
+	return nil
+}
+
+// upload sends names of files in one multipart request.
+// The request is sent again after seeking the readers back to offs.
+// When upload returns, the readers aren't used anymore.
+func (h *WorkspaceHandle) upload(ctx context.Context, names []string, files map[string]io.Reader, offs map[string]int64) error {
+	body, mw, done := multipartBody(names, files, "")
+
+	// stop ends the writer of the current body, the transport might not
+	// have read or closed it if the server answered early
+	stop := func() {
+		body.Close()
+		<-done
+	}
+
+	req, err := h.client.NewReaderRequest("POST", fmt.Sprintf("workspace/%s", h.id), body, mw.FormDataContentType())
+	if err != nil {
+		stop()
+		return err
+	}
+
+	req.GetBody = func() (io.ReadCloser, error) {
+		stop()
+		if err := seekReaders(files, offs); err != nil {
+			return nil, err
+		}
+		body, _, done = multipartBody(names, files, mw.Boundary())
+		return body, nil
+	}
+
+	_, _, err = h.client.DoPlain(ctx, req)
+	stop()
+	return err
+}
+
+// multipartBody streams names of files as "file" parts through a pipe.
+// The writer stops once the returned body is closed, done is closed after
+// it stopped reading files.
+func multipartBody(names []string, files map[string]io.Reader, boundary string) (io.ReadCloser, *multipart.Writer, <-chan struct{}) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	if boundary != "" {
+		mw.SetBoundary(boundary)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, name := range names {
+			part, err := mw.CreateFormFile("file", name)
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+
+			if _, err := io.Copy(part, files[name]); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+		pw.CloseWithError(mw.Close())
+	}()
+
+	return pr, mw, done
+}
+
+// rewindable returns files with the readers that can't seek replaced by
+// buffered copies of their remaining content. cleanup removes the temporary
+// files of large copies.
+func rewindable(files map[string]io.Reader) (_ map[string]io.Reader, cleanup func(), err error) {
+	var temps []*os.File
+	cleanup = func() {
+		for _, f := range temps {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+
+	out := make(map[string]io.Reader, len(files))
+	for name, r := range files {
+		if s, ok := r.(io.Seeker); ok {
+			if _, err := s.Seek(0, io.SeekCurrent); err == nil {
+				out[name] = r
+				continue
+			}
+		}
+
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, io.LimitReader(r, uploadBufferSize+1)); err != nil {
+			return nil, nil, fmt.Errorf("buffering %s failed: %w", name, err)
+		}
+		if buf.Len() <= uploadBufferSize {
+			out[name] = bytes.NewReader(buf.Bytes())
+			continue
+		}
+
+		f, err := os.CreateTemp("", "pshdl-upload-*")
+		if err != nil {
+			return nil, nil, err
+		}
+		temps = append(temps, f)
+		if _, err := io.Copy(f, io.MultiReader(&buf, r)); err != nil {
+			return nil, nil, fmt.Errorf("buffering %s failed: %w", name, err)
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return nil, nil, err
+		}
+		out[name] = f
+	}
+
+	return out, cleanup, nil
+}
+
+// readerOffsets returns the current offsets of all files, which must be seekable
+func readerOffsets(files map[string]io.Reader) (map[string]int64, error) {
+	offs := make(map[string]int64, len(files))
+	for name, r := range files {
+		s, ok := r.(io.Seeker)
+		if !ok {
+			return nil, fmt.Errorf("%s can't seek", name)
+		}
+
+		off, err := s.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		offs[name] = off
+	}
+	return offs, nil
+}
+
+func seekReaders(files map[string]io.Reader, offs map[string]int64) error {
+	for name, off := range offs {
+		if _, err := files[name].(io.Seeker).Seek(off, io.SeekStart); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// multiUploadRejected tells if err is the API refusing several files in one
+// request. Other client errors like 400 are returned as they are, a single
+// file upload wouldn't fix them.
+func multiUploadRejected(err error) bool {
+	var errResp *ErrorResponse
+	return errors.As(err, &errResp) && errResp.Response.StatusCode == http.StatusUnsupportedMediaType
+}
@@ -0,0 +1,237 @@
+package pshdlApi
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestUploadFiles(t *testing.T) {
+	Convey("Given a test server that records uploads", t, func() {
+		setup()
+
+		var (
+			mu       sync.Mutex
+			requests [][]string            // file names per request
+			uploaded = map[string]string{} // name -> content
+			multiOK  = true
+			rejectAs = http.StatusUnsupportedMediaType
+			failNext int
+			failOne  bool // fail single file requests
+		)
+
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			mr, err := r.MultipartReader()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			var names []string
+			got := map[string]string{}
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				c, _ := ioutil.ReadAll(part)
+				names = append(names, part.FileName())
+				got[part.FileName()] = string(c)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			requests = append(requests, names)
+			if failNext > 0 {
+				failNext--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if len(names) == 1 && failOne {
+				http.Error(w, "broken", http.StatusBadRequest)
+				return
+			}
+			if len(names) > 1 && !multiOK {
+				http.Error(w, "one file per request", rejectAs)
+				return
+			}
+			for n, c := range got {
+				uploaded[n] = c
+			}
+		})
+
+		files := func() map[string]io.Reader {
+			return map[string]io.Reader{
+				"b.pshdl": strings.NewReader("module b {}"),
+				"a.pshdl": strings.NewReader("module a {}"),
+				"c.pshdl": strings.NewReader("module c {}"),
+			}
+		}
+		want := map[string]string{
+			"a.pshdl": "module a {}",
+			"b.pshdl": "module b {}",
+			"c.pshdl": "module c {}",
+		}
+
+		Convey("UploadFiles() should send all files in one sorted request", func() {
+			err := client.Workspace.UploadFiles(context.Background(), files())
+			So(err, ShouldBeNil)
+			So(requests, ShouldResemble, [][]string{{"a.pshdl", "b.pshdl", "c.pshdl"}})
+			So(uploaded, ShouldResemble, want)
+		})
+
+		Convey("if the API rejects several files", func() {
+			multiOK = false
+
+			Convey("UploadFiles() should fall back to one request per file", func() {
+				err := client.Workspace.UploadFiles(context.Background(), files())
+				So(err, ShouldBeNil)
+				So(requests, ShouldHaveLength, 4)
+				So(uploaded, ShouldResemble, want)
+
+				Convey("and stick with it", func() {
+					requests = nil
+					err := client.Workspace.UploadFiles(context.Background(), files())
+					So(err, ShouldBeNil)
+					So(requests, ShouldResemble, [][]string{{"a.pshdl"}, {"b.pshdl"}, {"c.pshdl"}})
+				})
+			})
+
+			Convey("UploadFiles() should fall back with readers that can't seek", func() {
+				unseekable := map[string]io.Reader{}
+				for n, r := range files() {
+					unseekable[n] = io.MultiReader(r)
+				}
+
+				err := client.Workspace.UploadFiles(context.Background(), unseekable)
+				So(err, ShouldBeNil)
+				So(requests, ShouldHaveLength, 4)
+				So(uploaded, ShouldResemble, want)
+			})
+
+			Convey("UploadFiles() should not switch to single files if the fallback fails", func() {
+				failOne = true
+				err := client.Workspace.UploadFiles(context.Background(), files())
+				So(err, ShouldNotBeNil)
+				So(requests, ShouldHaveLength, 2)
+
+				requests = nil
+				multiOK = true
+				err = client.Workspace.UploadFiles(context.Background(), files())
+				So(err, ShouldBeNil)
+				So(requests, ShouldResemble, [][]string{{"a.pshdl", "b.pshdl", "c.pshdl"}})
+			})
+		})
+
+		Convey("if the API answers several files with another error", func() {
+			multiOK = false
+			rejectAs = http.StatusBadRequest
+
+			Convey("UploadFiles() should return it and keep sending several files", func() {
+				err := client.Workspace.UploadFiles(context.Background(), files())
+				So(err, ShouldNotBeNil)
+				So(requests, ShouldHaveLength, 1)
+
+				multiOK = true
+				err = client.Workspace.UploadFiles(context.Background(), files())
+				So(err, ShouldBeNil)
+				So(requests, ShouldHaveLength, 2)
+				So(uploaded, ShouldResemble, want)
+			})
+		})
+
+		Convey("UploadFile() should be retried with a seekable reader", func() {
+			client.retry = testRetryPolicy
+			client.retry.RetryNonIdempotent = true
+			failNext = 1
+
+			err := client.Workspace.UploadFile(context.Background(), "a.pshdl", strings.NewReader("module a {}"))
+			So(err, ShouldBeNil)
+			So(requests, ShouldHaveLength, 2)
+			So(uploaded, ShouldResemble, map[string]string{"a.pshdl": "module a {}"})
+		})
+
+		Convey("UploadFile() should be retried with a large reader that can't seek", func() {
+			client.retry = testRetryPolicy
+			client.retry.RetryNonIdempotent = true
+			failNext = 1
+
+			big := strings.Repeat("x", uploadBufferSize+10)
+			err := client.Workspace.UploadFile(context.Background(), "big.pshdl", io.MultiReader(strings.NewReader(big)))
+			So(err, ShouldBeNil)
+			So(requests, ShouldHaveLength, 2)
+			So(uploaded["big.pshdl"], ShouldEqual, big)
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestUploadFilesEarlyReject(t *testing.T) {
+	Convey("Given a test server that rejects several files before reading them", t, func() {
+		setup()
+
+		var (
+			mu       sync.Mutex
+			calls    int
+			uploaded = map[string]string{}
+		)
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			calls++
+			first := calls == 1
+			mu.Unlock()
+			if first {
+				http.Error(w, "one file per request", http.StatusUnsupportedMediaType)
+				return
+			}
+
+			mr, err := r.MultipartReader()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				c, _ := ioutil.ReadAll(part)
+				mu.Lock()
+				uploaded[part.FileName()] = string(c)
+				mu.Unlock()
+			}
+		})
+
+		Convey("UploadFiles() should send the complete files one by one", func() {
+			want := map[string]string{}
+			files := map[string]io.Reader{}
+			for _, n := range []string{"a", "b", "c"} {
+				content := strings.Repeat("module "+n+" {}\n", 64<<10)
+				want[n+".pshdl"] = content
+				files[n+".pshdl"] = strings.NewReader(content)
+			}
+
+			err := client.Workspace.UploadFiles(context.Background(), files)
+			So(err, ShouldBeNil)
+			So(calls, ShouldEqual, 4)
+			So(uploaded, ShouldResemble, want)
+		})
+
+		Reset(teardown)
+	})
+}
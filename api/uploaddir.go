@@ -0,0 +1,124 @@
+package pshdlApi
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// UploadDirOptions configure UploadDir
+type UploadDirOptions struct {
+	// Match selects the files to upload by their workspace relative path,
+	// all .pshdl files if nil.
+	Match func(relPath string) bool
+
+	// DryRun only reports what would be uploaded
+	DryRun bool
+}
+
+// UploadReport lists the files UploadDir looked at by their workspace relative paths
+type UploadReport struct {
+	Uploaded []string
+	// Ignored are the paths matched by an ignore file, a directory stands for all its content
+	Ignored []string
+	// Unmatched are the files Match didn't select
+	Unmatched []string
+}
+
+func isPshdlFile(relPath string) bool {
+	return path.Ext(relPath) == ".pshdl"
+}
+
+// UploadDir uploads the files in the tree dir to the workspace. Their names
+// are their paths relative to dir with forward slashes. A .pshdlignore file
+// in any directory excludes paths below it, using gitignore syntax.
+func (h *WorkspaceHandle) UploadDir(ctx context.Context, dir string, opts UploadDirOptions) (*UploadReport, error) {
+	if h.id == "" {
+		return nil, ErrNoWorkspaceID
+	}
+
+	report, err := scanDir(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.DryRun || len(report.Uploaded) == 0 {
+		return report, nil
+	}
+
+	files := make(map[string]io.Reader, len(report.Uploaded))
+	for _, name := range report.Uploaded {
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			closeAll(files)
+			return nil, err
+		}
+		files[name] = f
+	}
+	defer closeAll(files)
+
+	if err := h.UploadFiles(ctx, files); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// scanDir walks dir and sorts its files into the fields of the report
+func scanDir(dir string, opts UploadDirOptions) (*UploadReport, error) {
+	match := opts.Match
+	if match == nil {
+		match = isPshdlFile
+	}
+
+	ign, err := LoadIgnore(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	report := new(UploadReport)
+	err = filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, fpath)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel != "." && ign.Match(rel, true) {
+				report.Ignored = append(report.Ignored, rel+"/")
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		switch {
+		case d.Name() == IgnoreFile:
+		case ign.Match(rel, false):
+			report.Ignored = append(report.Ignored, rel)
+		case !d.Type().IsRegular() || !match(rel):
+			report.Unmatched = append(report.Unmatched, rel)
+		default:
+			report.Uploaded = append(report.Uploaded, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+func closeAll(files map[string]io.Reader) {
+	for _, r := range files {
+		r.(io.Closer).Close()
+	}
+}
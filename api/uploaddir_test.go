@@ -0,0 +1,137 @@
+package pshdlApi
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// partFileName returns the file name of part including directories,
+// which multipart.Part.FileName strips.
+func partFileName(part *multipart.Part) string {
+	_, params, _ := mime.ParseMediaType(part.Header.Get("Content-Disposition"))
+	return params["filename"]
+}
+
+func TestIgnore(t *testing.T) {
+	Convey("Given some ignore patterns", t, func() {
+		ign, err := ParseIgnore(strings.NewReader(`
+# comment
+*.bak
+/build/
+tmp/
+docs/**/draft.pshdl
+!keep.bak
+gen?.pshdl
+\#literal
+`))
+		So(err, ShouldBeNil)
+
+		for _, tc := range []struct {
+			path  string
+			isDir bool
+			want  bool
+		}{
+			{"a.bak", false, true},
+			{"pkg/a.bak", false, true},
+			{"keep.bak", false, false},
+			{"build", true, true},
+			{"pkg/build", true, false},
+			{"tmp", true, true},
+			{"pkg/tmp", true, true},
+			{"tmp", false, false},
+			{"docs/draft.pshdl", false, true},
+			{"docs/a/b/draft.pshdl", false, true},
+			{"draft.pshdl", false, false},
+			{"gen1.pshdl", false, true},
+			{"gen10.pshdl", false, false},
+			{"#literal", false, true},
+			{"comment", false, false},
+			{"alu.pshdl", false, false},
+		} {
+			So(ign.Match(tc.path, tc.isDir), ShouldEqual, tc.want)
+		}
+	})
+}
+
+func TestUploadDir(t *testing.T) {
+	Convey("Given a tree of PSHDL sources", t, func() {
+		setup()
+
+		dir := t.TempDir()
+		for name, content := range map[string]string{
+			"top.pshdl":            "module top {}",
+			"pkg/alu.pshdl":        "module pkg.alu {}",
+			"pkg/sub/reg.pshdl":    "module pkg.sub.reg {}",
+			"pkg/old.pshdl":        "module pkg.old {}",
+			"pkg/.pshdlignore":     "old.pshdl\n",
+			"build/out.pshdl":      "module out {}",
+			"README.md":            "docs",
+			".pshdlignore":         "/build/\n",
+			".git/objects/x.pshdl": "not a module",
+			"src-gen/psex/c/top.c": "int x;",
+			"pkg/sub/.pshdlignore": "# nothing\n",
+		} {
+			fname := filepath.Join(dir, filepath.FromSlash(name))
+			So(os.MkdirAll(filepath.Dir(fname), 0755), ShouldBeNil)
+			So(ioutil.WriteFile(fname, []byte(content), 0644), ShouldBeNil)
+		}
+
+		uploaded := map[string]string{}
+		mux.HandleFunc("/api/v0.1/workspace/1234", func(w http.ResponseWriter, r *http.Request) {
+			mr, err := r.MultipartReader()
+			So(err, ShouldBeNil)
+			for {
+				part, err := mr.NextPart()
+				if err == io.EOF {
+					break
+				}
+				So(err, ShouldBeNil)
+				c, _ := ioutil.ReadAll(part)
+				uploaded[partFileName(part)] = string(c)
+			}
+		})
+
+		Convey("UploadDir() should upload the matching files with their relative paths", func() {
+			report, err := client.OpenWorkspace("1234").UploadDir(context.Background(), dir, UploadDirOptions{})
+			So(err, ShouldBeNil)
+
+			So(report.Uploaded, ShouldResemble, []string{"pkg/alu.pshdl", "pkg/sub/reg.pshdl", "top.pshdl"})
+			So(report.Ignored, ShouldResemble, []string{".git/", "build/", "pkg/old.pshdl"})
+			So(report.Unmatched, ShouldResemble, []string{"README.md", "src-gen/psex/c/top.c"})
+
+			So(uploaded, ShouldResemble, map[string]string{
+				"top.pshdl":         "module top {}",
+				"pkg/alu.pshdl":     "module pkg.alu {}",
+				"pkg/sub/reg.pshdl": "module pkg.sub.reg {}",
+			})
+		})
+
+		Convey("UploadDir() with DryRun should only report", func() {
+			report, err := client.Workspace.UploadDir(context.Background(), dir, UploadDirOptions{DryRun: true})
+			So(err, ShouldBeNil)
+			So(report.Uploaded, ShouldHaveLength, 3)
+			So(uploaded, ShouldBeEmpty)
+		})
+
+		Convey("UploadDir() should use Match", func() {
+			report, err := client.Workspace.UploadDir(context.Background(), dir, UploadDirOptions{
+				Match: func(relPath string) bool { return strings.HasPrefix(relPath, "pkg/sub/") },
+			})
+			So(err, ShouldBeNil)
+			So(report.Uploaded, ShouldResemble, []string{"pkg/sub/reg.pshdl"})
+			So(uploaded, ShouldHaveLength, 1)
+		})
+
+		Reset(teardown)
+	})
+}
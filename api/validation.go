@@ -0,0 +1,163 @@
+package pshdlApi
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Severity ranks problems, higher is more severe
+type Severity int
+
+// The severities the compiler reports
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// ParseSeverity returns the severity for a name like "error" or "WARNING"
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToUpper(s) {
+	case "INFO", "HINT":
+		return SeverityInfo, nil
+	case "WARNING", "WARN":
+		return SeverityWarning, nil
+	case "ERROR":
+		return SeverityError, nil
+	}
+	return 0, fmt.Errorf("unknown severity %q", s)
+}
+
+// Level returns the severity of the problem. Unknown severities count as errors.
+func (p Problem) Level() Severity {
+	s, err := ParseSeverity(p.Severity)
+	if err != nil {
+		return SeverityError
+	}
+	return s
+}
+
+// FileProblems are the problems of one file grouped by severity
+type FileProblems struct {
+	Path     string
+	Errors   []Problem
+	Warnings []Problem
+	Infos    []Problem
+}
+
+// Problems returns the problems of the file with severity s
+func (f *FileProblems) Problems(s Severity) []Problem {
+	switch s {
+	case SeverityError:
+		return f.Errors
+	case SeverityWarning:
+		return f.Warnings
+	}
+	return f.Infos
+}
+
+func (f *FileProblems) add(p Problem) {
+	switch p.Level() {
+	case SeverityError:
+		f.Errors = append(f.Errors, p)
+	case SeverityWarning:
+		f.Warnings = append(f.Warnings, p)
+	default:
+		f.Infos = append(f.Infos, p)
+	}
+}
+
+func (f *FileProblems) len() int {
+	return len(f.Errors) + len(f.Warnings) + len(f.Infos)
+}
+
+// ValidationResult summarizes the problems of a validated workspace
+type ValidationResult struct {
+	Workspace *Workspace
+	// LastValidation is the time of the validation, the zero time if unknown
+	LastValidation time.Time
+	// Files are the files with problems, sorted by path
+	Files []FileProblems
+
+	ErrorCount   int
+	WarningCount int
+	InfoCount    int
+}
+
+// NewValidationResult collects the problems of the files in w
+func NewValidationResult(w *Workspace) *ValidationResult {
+	r := &ValidationResult{
+		Workspace:      w,
+		LastValidation: millisTime(w.LastValidation),
+	}
+
+	for _, f := range w.Files {
+		fp := FileProblems{Path: strings.TrimPrefix(path.Clean(f.Record.RelPath), "/")}
+		for _, p := range f.Info.Problems {
+			fp.add(p)
+		}
+		r.addFile(fp)
+	}
+
+	sort.SliceStable(r.Files, func(i, j int) bool { return r.Files[i].Path < r.Files[j].Path })
+	return r
+}
+
+func (r *ValidationResult) addFile(fp FileProblems) {
+	if fp.len() == 0 {
+		return
+	}
+	r.Files = append(r.Files, fp)
+	r.ErrorCount += len(fp.Errors)
+	r.WarningCount += len(fp.Warnings)
+	r.InfoCount += len(fp.Infos)
+}
+
+// Clean tells if the workspace was validated without any problems
+func (r *ValidationResult) Clean() bool {
+	return r.Workspace.Validated && r.ErrorCount+r.WarningCount+r.InfoCount == 0
+}
+
+// HasErrors tells if any file has a problem of SeverityError
+func (r *ValidationResult) HasErrors() bool {
+	return r.ErrorCount > 0
+}
+
+// Filter returns a copy of the result with only the problems of at least severity min
+func (r *ValidationResult) Filter(min Severity) *ValidationResult {
+	filtered := &ValidationResult{
+		Workspace:      r.Workspace,
+		LastValidation: r.LastValidation,
+	}
+
+	for _, f := range r.Files {
+		fp := FileProblems{Path: f.Path}
+		if min <= SeverityError {
+			fp.Errors = f.Errors
+		}
+		if min <= SeverityWarning {
+			fp.Warnings = f.Warnings
+		}
+		if min <= SeverityInfo {
+			fp.Infos = f.Infos
+		}
+		filtered.addFile(fp)
+	}
+
+	return filtered
+}
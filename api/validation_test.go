@@ -0,0 +1,104 @@
+package pshdlApi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidationResult(t *testing.T) {
+	Convey("Given a clean test server", t, func() {
+		setup()
+
+		Convey("Validate() should group the problems by file and severity", func() {
+			mux.HandleFunc("/api/v0.1/compiler/1234/validate", func(w http.ResponseWriter, r *http.Request) {
+				So(r.Method, ShouldEqual, "POST")
+				fmt.Fprint(w, `{"id":"1234","validated":true,"lastValidation":1400000000000,"files":[
+					{"record":{"relPath":"b.pshdl"},"info":{"problems":[
+						{"errorCode":"E1","severity":"ERROR"},
+						{"errorCode":"W1","severity":"WARNING"},
+						{"errorCode":"I1","severity":"INFO"}
+					]}},
+					{"record":{"relPath":"ok.pshdl"}},
+					{"record":{"relPath":"a.pshdl"},"info":{"problems":[
+						{"errorCode":"W2","severity":"WARNING"}
+					]}}
+				]}`)
+			})
+
+			res, err := client.Compiler.Validate(context.Background())
+			So(err, ShouldBeNil)
+			So(res.Workspace.ID, ShouldEqual, "1234")
+			So(res.LastValidation.Equal(time.UnixMilli(1400000000000)), ShouldBeTrue)
+
+			So(res.Files, ShouldHaveLength, 2)
+			So(res.Files[0].Path, ShouldEqual, "a.pshdl")
+			So(res.Files[0].Warnings[0].ErrorCode, ShouldEqual, "W2")
+			So(res.Files[1].Path, ShouldEqual, "b.pshdl")
+			So(res.Files[1].Problems(SeverityError)[0].ErrorCode, ShouldEqual, "E1")
+			So(res.Files[1].Problems(SeverityInfo)[0].ErrorCode, ShouldEqual, "I1")
+
+			So(res.ErrorCount, ShouldEqual, 1)
+			So(res.WarningCount, ShouldEqual, 2)
+			So(res.InfoCount, ShouldEqual, 1)
+			So(res.HasErrors(), ShouldBeTrue)
+			So(res.Clean(), ShouldBeFalse)
+
+			Convey("and Filter() should drop less severe problems", func() {
+				errs := res.Filter(SeverityError)
+				So(errs.Files, ShouldHaveLength, 1)
+				So(errs.Files[0].Path, ShouldEqual, "b.pshdl")
+				So(errs.Files[0].Warnings, ShouldBeNil)
+				So(errs.ErrorCount, ShouldEqual, 1)
+				So(errs.WarningCount, ShouldEqual, 0)
+
+				warns := res.Filter(SeverityWarning)
+				So(warns.Files, ShouldHaveLength, 2)
+				So(warns.WarningCount, ShouldEqual, 2)
+				So(warns.InfoCount, ShouldEqual, 0)
+
+				So(res.Filter(SeverityInfo), ShouldResemble, res)
+			})
+		})
+
+		Convey("Validate() of a workspace without problems should be clean", func() {
+			mux.HandleFunc("/api/v0.1/compiler/1234/validate", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"id":"1234","validated":true,"files":[{"record":{"relPath":"ok.pshdl"}}]}`)
+			})
+
+			res, err := client.Compiler.Validate(context.Background())
+			So(err, ShouldBeNil)
+			So(res.Clean(), ShouldBeTrue)
+			So(res.HasErrors(), ShouldBeFalse)
+			So(res.Files, ShouldBeEmpty)
+			So(res.LastValidation.IsZero(), ShouldBeTrue)
+		})
+
+		Reset(teardown)
+	})
+}
+
+func TestSeverity(t *testing.T) {
+	Convey("ParseSeverity()", t, func() {
+		for name, want := range map[string]Severity{"ERROR": SeverityError, "warning": SeverityWarning, "Info": SeverityInfo} {
+			s, err := ParseSeverity(name)
+			So(err, ShouldBeNil)
+			So(s, ShouldEqual, want)
+
+			back, err := ParseSeverity(s.String())
+			So(err, ShouldBeNil)
+			So(back, ShouldEqual, s)
+		}
+
+		_, err := ParseSeverity("fatal")
+		So(err, ShouldNotBeNil)
+
+		Convey("unknown severities of problems should count as errors", func() {
+			So(Problem{Severity: "FATAL"}.Level(), ShouldEqual, SeverityError)
+		})
+	})
+}
@@ -1,18 +1,15 @@
 package pshdlApi
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"net/url"
-	"os"
 	"path"
 	"regexp"
-	"strconv"
 	"strings"
-	"time"
 )
 
 const (
@@ -21,7 +18,8 @@ const (
 )
 
 // WorkspaceService handles communication with the workspace related
-// methods of the PsHdl REST API.
+// methods of the PsHdl REST API for the workspace with ID.
+// Use Client.OpenWorkspace to work with several workspaces at once.
 type WorkspaceService struct {
 	// wrapped http client
 	client *Client
@@ -29,216 +27,195 @@ type WorkspaceService struct {
 	ID string
 }
 
-// Create creates a new Workspace on the Rest API
-// Currently using form encoded post, want json..!
-func (s *WorkspaceService) Create() (*Workspace, *http.Response, error) {
-	// prepare request
-	param := url.Values{}
-	param.Set("name", defaultName)
-	param.Set("eMail", defaultEmail)
-
-	req, err := s.client.NewReaderRequest("POST", "workspace", strings.NewReader(param.Encode()), "")
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// do the request
-	body, resp, err := s.client.DoPlain(req)
+// Create creates a new Workspace on the Rest API and sets the ID of all
+// services of the client to it.
+func (s *WorkspaceService) Create(ctx context.Context, opts CreateOptions) (*Workspace, *http.Response, error) {
+	w, resp, err := s.client.createWorkspace(ctx, opts)
 	if err != nil {
 		return nil, resp, err
 	}
 
-	wsCreatedRegex := regexp.MustCompile(`/api/v0.1/workspace/([0-9A-F]*)`)
-	matches := wsCreatedRegex.FindSubmatch(body)
-	if len(matches) != 2 {
-		return nil, resp, fmt.Errorf("no Workspace ID - %s", string(body))
-	}
-
-	s.ID = string(matches[1])
-	w := &Workspace{
-		ID: s.ID,
-	}
+	s.ID = w.ID
+	s.client.Compiler.ID = w.ID
+	s.client.Streaming.ID = w.ID
 
 	return w, resp, nil
 }
 
-// GetInfo gets all the info there is to get for a PSHDL Workspace
-func (s *WorkspaceService) GetInfo() (*Workspace, *http.Response, error) {
-	req, err := s.client.NewRequest("GET", "workspace/"+s.ID, nil)
-	if err != nil {
-		return nil, nil, err
-	}
+// GetInfo gets all the info there is to get for the workspace
+func (s *WorkspaceService) GetInfo(ctx context.Context) (*Workspace, *http.Response, error) {
+	return s.client.OpenWorkspace(s.ID).GetInfo(ctx)
+}
 
-	w := new(Workspace)
-	resp, err := s.client.Do(req, w)
-	if err != nil {
-		return nil, resp, err
-	}
+// Delete removes the file `fname` from the workspace
+func (s *WorkspaceService) Delete(ctx context.Context, fname string) (bool, *http.Response, error) {
+	return s.client.OpenWorkspace(s.ID).Delete(ctx, fname)
+}
 
-	if w.ID != s.ID {
-		return nil, nil, fmt.Errorf("we got response for %v a different workspace", w)
-	}
+// UploadFile adds a file with fname to the workspace
+func (s *WorkspaceService) UploadFile(ctx context.Context, fname string, fbuf io.Reader) error {
+	return s.client.OpenWorkspace(s.ID).UploadFile(ctx, fname, fbuf)
+}
 
-	return w, resp, err
+// UploadFiles adds all files to the workspace, see WorkspaceHandle.UploadFiles
+func (s *WorkspaceService) UploadFiles(ctx context.Context, files map[string]io.Reader) error {
+	return s.client.OpenWorkspace(s.ID).UploadFiles(ctx, files)
 }
 
-// Delete removes the file `fname` from the specified workspace
-func (s *WorkspaceService) Delete(fname string) (bool, *http.Response, error) {
-	if s.ID == "" {
-		return false, nil, fmt.Errorf("workspace ID not set")
-	}
+// UploadDir uploads the files in the tree dir, see WorkspaceHandle.UploadDir
+func (s *WorkspaceService) UploadDir(ctx context.Context, dir string, opts UploadDirOptions) (*UploadReport, error) {
+	return s.client.OpenWorkspace(s.ID).UploadDir(ctx, dir, opts)
+}
 
-	req, err := s.client.NewRequest("DELETE", fmt.Sprintf("workspace/%s/%s", s.ID, fname), nil)
-	if err != nil {
-		return false, nil, err
-	}
+// Diff compares the local directory dir with the workspace, see WorkspaceHandle.Diff
+func (s *WorkspaceService) Diff(ctx context.Context, dir string, w *Workspace) ([]FileDiff, error) {
+	return s.client.OpenWorkspace(s.ID).Diff(ctx, dir, w)
+}
 
-	_, resp, err := s.client.DoPlain(req)
-	if err != nil {
-		return false, resp, err
-	}
+// Open returns the content of the file relPath, see WorkspaceHandle.Open
+func (s *WorkspaceService) Open(ctx context.Context, relPath string) (io.ReadCloser, error) {
+	return s.client.OpenWorkspace(s.ID).Open(ctx, relPath)
+}
 
-	if resp.StatusCode != 200 {
-		return false, resp, fmt.Errorf("file was not deleted")
-	}
+// ReadFile returns the content of the file relPath, see WorkspaceHandle.ReadFile
+func (s *WorkspaceService) ReadFile(ctx context.Context, relPath string) ([]byte, error) {
+	return s.client.OpenWorkspace(s.ID).ReadFile(ctx, relPath)
+}
 
-	return true, resp, err
+// DownloadRecords downloads all recs, see WorkspaceHandle.DownloadRecords
+func (s *WorkspaceService) DownloadRecords(ctx context.Context, recs []Record, opts DownloadOptions) error {
+	return s.client.OpenWorkspace(s.ID).DownloadRecords(ctx, recs, opts)
 }
 
-// UploadFile adds a file with fname to the Workspace specified by ID
-func (s *WorkspaceService) UploadFile(fname string, fbuf io.Reader) error {
-	dbg("Workspace(%s) UploadFile(%s)", s.ID, fname)
+// DownloadRecord downloads rec, see WorkspaceHandle.DownloadRecord
+func (s *WorkspaceService) DownloadRecord(ctx context.Context, rec Record, opts DownloadOptions) error {
+	return s.client.OpenWorkspace(s.ID).DownloadRecord(ctx, rec, opts)
+}
 
-	if s.ID == "" {
-		return fmt.Errorf("workspace ID not set")
-	}
+// CreateOptions are the details of a new workspace.
+// Empty fields are filled with defaults.
+type CreateOptions struct {
+	Name  string `json:"name"`
+	Email string `json:"eMail"`
+}
 
-	// convert Upload to Multipart
-	reqBody := &bytes.Buffer{}
-	writer := multipart.NewWriter(reqBody)
+// wsIDRegex matches a workspace ID
+var wsIDRegex = regexp.MustCompile(`^[0-9A-Fa-f]+$`)
 
-	part, err := writer.CreateFormFile("file", fname)
-	if err != nil {
-		return err
-	}
+// createWorkspace creates a new Workspace on the Rest API and gets its info
+func (c *Client) createWorkspace(ctx context.Context, opts CreateOptions) (*Workspace, *http.Response, error) {
+	ctx = withOperation(ctx, "Workspace", "Create")
 
-	_, err = io.Copy(part, fbuf)
-	if err != nil {
-		return err
+	if opts.Name == "" {
+		opts.Name = defaultName
+	}
+	if opts.Email == "" {
+		opts.Email = defaultEmail
 	}
 
-	err = writer.Close()
+	req, err := c.NewRequest("POST", "workspace", opts)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
+	req.Header.Set("Content-Type", "application/json")
 
-	// prepare request
-	req, err := s.client.NewReaderRequest("POST", fmt.Sprintf("workspace/%s", s.ID), reqBody, writer.FormDataContentType())
+	body, resp, err := c.DoPlain(ctx, req)
 	if err != nil {
-		return err
+		return nil, resp, err
 	}
 
-	// do the request
-	_, _, err = s.client.DoPlain(req)
+	id, err := createdWorkspaceID(resp, body)
 	if err != nil {
-		return err
+		return nil, resp, err
 	}
 
-	return nil
+	return c.OpenWorkspace(id).GetInfo(ctx)
 }
 
-// DownloadRecords starts DownloadRecord for each Record
-// in its own goroutine and waits until all are finished or one of them returns an error
-func (s *WorkspaceService) DownloadRecords(recs []Record) error {
-	errc := make(chan error)
-	fileCount := len(recs)
-
-	if fileCount == 0 {
-		return nil
+// createdWorkspaceID finds the ID of a created workspace in the Location header,
+// a JSON body with an id or the URL of the workspace in a plain body.
+func createdWorkspaceID(resp *http.Response, body []byte) (string, error) {
+	if loc := resp.Header.Get("Location"); loc != "" {
+		if id, ok := workspaceURLID(loc); ok {
+			return id, nil
+		}
 	}
 
-	start := time.Now()
+	var created struct {
+		ID string `json:"id"`
+	}
+	if json.Unmarshal(body, &created) == nil && created.ID != "" {
+		return created.ID, nil
+	}
 
-	for _, r := range recs {
-		go func(rec Record) {
-			err := s.DownloadRecord(rec)
-			if err != nil {
-				errc <- err //fmt.Fprintf(os.Stderr, "Could not http.Get %s - %s\n", file.Record.RelPath, err)
-				return
-			}
-			errc <- nil
-		}(r)
+	if id, ok := workspaceURLID(strings.TrimSpace(string(body))); ok {
+		return id, nil
 	}
 
-	for {
-		select {
-		case err := <-errc:
-			if err != nil {
-				close(errc)
-				return fmt.Errorf("could not load all files. Error: %s", err)
-			}
+	return "", fmt.Errorf("no Workspace ID - %s", string(body))
+}
 
-			fileCount--
-			if fileCount == 0 {
-				return nil
-			}
+// workspaceURLID returns the ID from the URL of a workspace, independent of
+// the base URL. The ID has to be the last segment of the path, after "workspace".
+func workspaceURLID(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", false
+	}
 
-		case <-time.After(5 * time.Second):
-			fmt.Fprintf(os.Stderr, "DownloadRecords() Waiting.. \n%d files left. Duration: %s\n", fileCount, time.Since(start))
-		}
+	p := strings.TrimSuffix(u.Path, "/")
+	id := path.Base(p)
+	if path.Base(path.Dir(p)) != "workspace" || !wsIDRegex.MatchString(id) {
+		return "", false
 	}
+	return id, true
 }
 
-// DownloadRecord returns a copy of fname
-func (s *WorkspaceService) DownloadRecord(rec Record) error {
-	dbg("Workspace(%s) DownloadRecord(%s)", s.ID, rec.RelPath)
-	if s.ID == "" {
-		return fmt.Errorf("workspace ID not set")
+// GetInfo gets all the info there is to get for a PSHDL Workspace
+func (h *WorkspaceHandle) GetInfo(ctx context.Context) (*Workspace, *http.Response, error) {
+	ctx = withOperation(ctx, "Workspace", "GetInfo")
+	if h.id == "" {
+		return nil, nil, ErrNoWorkspaceID
 	}
 
-	req, err := s.client.NewRequest("GET", rec.FileURI, nil)
+	req, err := h.client.NewRequest("GET", "workspace/"+h.id, nil)
 	if err != nil {
-		return fmt.Errorf("client.NewRequest() error: %s", err)
+		return nil, nil, err
 	}
-	req.Header.Set("Accept", "text/plain")
 
-	resp, err := s.client.Do(req, nil)
+	w := new(Workspace)
+	resp, err := h.client.Do(ctx, req, w)
 	if err != nil {
-		return fmt.Errorf("client.Do(req) error: %s", err)
+		return nil, resp, err
 	}
-	defer resp.Body.Close()
 
-	dir, _ := path.Split(rec.RelPath)
-	if dir != "" {
-		err = os.MkdirAll(dir, 0700)
-		if err != nil {
-			return fmt.Errorf("os.MkdirAll() error: %s", err)
-		}
+	if w.ID != h.id {
+		return nil, nil, fmt.Errorf("we got response for %v a different workspace", w)
 	}
 
-	f, err := os.OpenFile(rec.RelPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0700)
-	if err != nil {
-		return fmt.Errorf("os.OpenFile() error: %s\nRecord:%v", err, rec)
+	return w, resp, err
+}
+
+// Delete removes the file `fname` from the workspace
+func (h *WorkspaceHandle) Delete(ctx context.Context, fname string) (bool, *http.Response, error) {
+	ctx = withOperation(ctx, "Workspace", "Delete")
+	if h.id == "" {
+		return false, nil, ErrNoWorkspaceID
 	}
-	defer f.Close()
 
-	copied, err := io.Copy(f, resp.Body)
+	req, err := h.client.NewRequest("DELETE", fmt.Sprintf("workspace/%s/%s", h.id, fname), nil)
 	if err != nil {
-		return err
+		return false, nil, err
 	}
 
-	if resp.Header.Get("Content-Length") != "" {
-		fileLength, err := strconv.Atoi(resp.Header.Get("Content-Length"))
-		if err != nil {
-			return err
-		}
+	_, resp, err := h.client.DoPlain(ctx, req)
+	if err != nil {
+		return false, resp, err
+	}
 
-		if int(copied) != fileLength {
-			return fmt.Errorf("io.Copy(f, resp.Body) did not copy the whole file. got <%d> wanted <%d>", copied, fileLength)
-		}
-		// } else {
-		// fmt.Fprintf(os.Stderr, "Warning, no Content-Length set.\nResp:%v\n", resp)
+	if resp.StatusCode != 200 {
+		return false, resp, ErrFileNotDeleted
 	}
 
-	return nil
+	return true, resp, err
 }
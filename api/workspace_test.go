@@ -2,10 +2,15 @@ package pshdlApi
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
-	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -16,24 +21,93 @@ func TestWorkspaceService(t *testing.T) {
 	Convey("Given a clean test server for the WorkspaceService", t, func() {
 		setup()
 
-		Convey("Create() should return a new Workspace", func() {
+		Convey("Create()", func() {
+			mux.HandleFunc("/api/v0.1/workspace/251C5321A7254D79", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, `{"id":"251C5321A7254D79","jsonVersion":"1.0","validated":true}`)
+			})
+			full := &Workspace{ID: "251C5321A7254D79", JSONVersion: "1.0", Validated: true}
+
+			Convey("should send the options as JSON", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					So(r.Method, ShouldEqual, "POST")
+					So(r.Header.Get("Content-Type"), ShouldEqual, "application/json")
 
-			mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
-				r.ParseForm()
+					var opts CreateOptions
+					So(json.NewDecoder(r.Body).Decode(&opts), ShouldBeNil)
+					So(opts, ShouldResemble, CreateOptions{Name: "Jane", Email: "jane@example.com"})
 
-				So(r.Form, ShouldResemble, url.Values{
-					"name":  []string{defaultName},
-					"eMail": []string{defaultEmail},
+					fmt.Fprint(w, "/api/v0.1/workspace/251C5321A7254D79")
 				})
 
-				So(r.Method, ShouldEqual, "POST")
+				workspace, _, err := client.Workspace.Create(context.Background(), CreateOptions{Name: "Jane", Email: "jane@example.com"})
+				So(err, ShouldBeNil)
+				So(workspace, ShouldResemble, full)
+			})
+
+			Convey("should fill in defaults", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					var opts CreateOptions
+					So(json.NewDecoder(r.Body).Decode(&opts), ShouldBeNil)
+					So(opts, ShouldResemble, CreateOptions{Name: defaultName, Email: defaultEmail})
+
+					fmt.Fprint(w, "/api/v0.1/workspace/251C5321A7254D79")
+				})
 
-				fmt.Fprint(w, "/api/v0.1/workspace/251C5321A7254D79")
+				_, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
 			})
 
-			workspace, _, err := client.Workspace.Create()
-			So(err, ShouldBeNil)
-			So(workspace, ShouldResemble, &Workspace{ID: "251C5321A7254D79"})
+			Convey("should use the Location header", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Location", "http://other.host/some/prefix/workspace/251C5321A7254D79")
+					w.WriteHeader(http.StatusCreated)
+				})
+
+				workspace, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(workspace, ShouldResemble, full)
+			})
+
+			Convey("should take the ID from the last segment of the Location header", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Location", "http://other.host/workspace/0123456789ABCDEF/workspace/251C5321A7254D79/")
+					w.WriteHeader(http.StatusCreated)
+				})
+
+				workspace, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(workspace, ShouldResemble, full)
+			})
+
+			Convey("should ignore a Location header that doesn't end with an ID", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Location", "http://other.host/workspace/0123456789ABCDEF/status")
+					fmt.Fprint(w, `{"id":"251C5321A7254D79"}`)
+				})
+
+				workspace, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(workspace, ShouldResemble, full)
+			})
+
+			Convey("should read a JSON response", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"id":"251C5321A7254D79"}`)
+				})
+
+				workspace, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldBeNil)
+				So(workspace, ShouldResemble, full)
+			})
+
+			Convey("should fail without an ID in the response", func() {
+				mux.HandleFunc("/api/v0.1/workspace", func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, "ok")
+				})
+
+				_, _, err := client.Workspace.Create(context.Background(), CreateOptions{})
+				So(err, ShouldNotBeNil)
+			})
 		})
 
 		Convey("GetInfo()", func() {
@@ -47,7 +121,7 @@ func TestWorkspaceService(t *testing.T) {
 
 			Convey("should return meta workspace info", func() {
 
-				workspace, _, err := client.Workspace.GetInfo()
+				workspace, _, err := client.Workspace.GetInfo(context.Background())
 				So(err, ShouldBeNil)
 				So(workspace.ID, ShouldEqual, "1234")
 				So(workspace.JSONVersion, ShouldEqual, "1.0")
@@ -57,7 +131,7 @@ func TestWorkspaceService(t *testing.T) {
 
 			Convey("should decode the File info", func() {
 
-				workspace, _, err := client.Workspace.GetInfo()
+				workspace, _, err := client.Workspace.GetInfo(context.Background())
 				So(err, ShouldBeNil)
 				So(workspace.Files, ShouldResemble, []File{
 					File{
@@ -86,13 +160,13 @@ func TestWorkspaceService(t *testing.T) {
 						http.Error(w, "", http.StatusOK)
 					})
 
-				done, _, err := client.Workspace.Delete(fname)
+				done, _, err := client.Workspace.Delete(context.Background(), fname)
 				So(err, ShouldBeNil)
 				So(done, ShouldBeTrue)
 			})
 
 			Convey("without an ID should return an error", func() {
-				_, _, err := client.Workspace.Delete("hansfranz.pshdl")
+				_, _, err := client.Workspace.Delete(context.Background(), "hansfranz.pshdl")
 				So(err, ShouldNotBeNil)
 			})
 
@@ -125,12 +199,12 @@ func TestWorkspaceService(t *testing.T) {
 						http.Error(w, "", http.StatusOK)
 					})
 
-				err := client.Workspace.UploadFile(fname, bytes.NewReader(content))
+				err := client.Workspace.UploadFile(context.Background(), fname, bytes.NewReader(content))
 				So(err, ShouldBeNil)
 			})
 
 			Convey("without an ID should return an error", func() {
-				err := client.Workspace.UploadFile("hansfranz.pshdl", bytes.NewReader([]byte("")))
+				err := client.Workspace.UploadFile(context.Background(), "hansfranz.pshdl", bytes.NewReader([]byte("")))
 				So(err, ShouldNotBeNil)
 			})
 		})
@@ -145,15 +219,58 @@ func TestWorkspaceService(t *testing.T) {
 				So(r.Header.Get("Accept"), ShouldEqual, "text/plain")
 				So(r.Method, ShouldEqual, "GET")
 
-				fmt.Fprintf(w, fContent)
+				fmt.Fprint(w, fContent)
+			})
+
+			dir := t.TempDir()
+			err := client.Workspace.DownloadRecord(context.Background(), Record{FileURI: testURL, RelPath: fName}, DownloadOptions{Dir: dir})
+			So(err, ShouldBeNil)
+
+			content, err := ioutil.ReadFile(filepath.Join(dir, fName))
+			So(err, ShouldBeNil)
+			So(string(content), ShouldEqual, fContent)
+		})
+
+		Convey("DownloadRecord() should create missing directories", func() {
+			mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, "module x {}")
 			})
 
-			err := client.Workspace.DownloadRecord(Record{FileURI: testURL, RelPath: fName})
+			dir := t.TempDir()
+			rec := Record{FileURI: "/api/v0.1/workspace/1234/src-gen/x.c", RelPath: "src-gen/psex/x.c"}
+			err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir, FileMode: 0600, DirMode: 0700})
+			So(err, ShouldBeNil)
+
+			fi, err := os.Stat(filepath.Join(dir, "src-gen", "psex"))
 			So(err, ShouldBeNil)
+			So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0700))
+
+			fi, err = os.Stat(filepath.Join(dir, "src-gen", "psex", "x.c"))
+			So(err, ShouldBeNil)
+			So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0600))
+		})
+
+		Convey("DownloadRecord() should reject paths outside of Dir", func() {
+			var hits int
+			mux.HandleFunc("/api/v0.1/workspace/1234/", func(w http.ResponseWriter, r *http.Request) {
+				hits++
+			})
+
+			root := t.TempDir()
+			dir := filepath.Join(root, "dl")
+			for _, relPath := range []string{"../evil.pshdl", "a/../../evil.pshdl", "/etc/evil.pshdl", "", "."} {
+				rec := Record{FileURI: "/api/v0.1/workspace/1234/evil.pshdl", RelPath: relPath}
+				err := client.Workspace.DownloadRecord(context.Background(), rec, DownloadOptions{Dir: dir})
+				So(errors.Is(err, ErrUnsafePath), ShouldBeTrue)
+			}
+			So(hits, ShouldEqual, 0)
+
+			_, err := os.Stat(filepath.Join(root, "evil.pshdl"))
+			So(os.IsNotExist(err), ShouldBeTrue)
 		})
 
 		Convey("DownloadRecord() without an ID", func() {
-			err := client.Workspace.DownloadRecord(Record{RelPath: "hansfranz.pshdl"})
+			err := client.Workspace.DownloadRecord(context.Background(), Record{RelPath: "hansfranz.pshdl"}, DownloadOptions{})
 			So(err, ShouldNotBeNil)
 		})
 
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/codegangsta/cli"
+	"github.com/cryptix/goPshdlRest/api"
+	"github.com/cryptix/goPshdlRest/api/problemfmt"
+)
+
+const (
+	appName  = "pshdl"
+	widFname = ".wid"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = appName
+	app.Usage = "work with PSHDL workspaces from the command line"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{Name: "workspace,w", Usage: "the workspace to use (default: read from .wid)"},
+	}
+	app.Commands = []cli.Command{
+		{
+			Name:  "push",
+			Usage: "upload a directory tree, honouring .pshdlignore files",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir,d", Value: ".", Usage: "the directory to upload"},
+				cli.BoolFlag{Name: "dry-run,n", Usage: "only list what would be uploaded"},
+				cli.BoolFlag{Name: "verbose,v", Usage: "list skipped files, too"},
+			},
+			Action: push,
+		},
+		{
+			Name:  "diff",
+			Usage: "show how a directory tree differs from the workspace",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir,d", Value: ".", Usage: "the directory to compare"},
+				cli.BoolFlag{Name: "name-only", Usage: "only list the paths that differ"},
+				cli.BoolFlag{Name: "verbose,v", Usage: "list unchanged files, too"},
+			},
+			Action: diff,
+		},
+		{
+			Name:  "compile",
+			Usage: "validate the workspace and download the generated code: compile [vhdl] [c]",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir,d", Value: ".", Usage: "the directory to download into"},
+				cli.DurationFlag{Name: "timeout,t", Value: pshdlApi.DefaultCompileTimeout, Usage: "how long to wait for the compilers"},
+			},
+			Action: compile,
+		},
+		{
+			Name:  "problems",
+			Usage: "validate the workspace and print its problems as file:line:col: lines for editors",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "explain,e", Usage: "add the explanations of the problems"},
+				cli.BoolFlag{Name: "solutions,s", Usage: "add the suggested solutions"},
+				cli.StringFlag{Name: "min,m", Value: "info", Usage: "the least severity to print: info, warning or error"},
+			},
+			Action: problems,
+		},
+		{
+			Name:  "clone",
+			Usage: "copy the sources of a workspace into a new one: clone <workspace>",
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "wid", Usage: "write the ID of the clone to .wid"},
+			},
+			Action: clone,
+		},
+		{
+			Name:  "export",
+			Usage: "write the workspace into a zip or tar.gz archive",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "out,o", Usage: "the archive to write, its extension selects the format"},
+				cli.StringFlag{Name: "format,f", Usage: "zip or tar.gz, overrides the extension"},
+				cli.BoolFlag{Name: "generated,g", Usage: "add generated code (src-gen)"},
+				cli.BoolFlag{Name: "manifest,m", Usage: "add a JSON manifest of records, module infos and problems"},
+			},
+			Action: export,
+		},
+		{
+			Name:  "import",
+			Usage: "upload the sources of an archive and validate the workspace",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "in,i", Usage: "the archive to read, its extension selects the format"},
+				cli.StringFlag{Name: "format,f", Usage: "zip or tar.gz, overrides the extension"},
+				cli.BoolFlag{Name: "new", Usage: "create a new workspace instead of using --workspace or .wid"},
+			},
+			Action: importArchive,
+		},
+	}
+
+	app.Run(os.Args)
+}
+
+// openWorkspace returns a handle for the workspace given with --workspace or in .wid
+// and a context that is cancelled on interrupt
+func openWorkspace(c *cli.Context) (context.Context, context.CancelFunc, *pshdlApi.WorkspaceHandle) {
+	wid := c.GlobalString("workspace")
+	if wid == "" {
+		data, err := ioutil.ReadFile(widFname)
+		if err != nil {
+			log.Fatalln("no workspace given and no .wid file:", err)
+		}
+		wid = strings.TrimSpace(string(data))
+	}
+
+	ctx, stop, client := newClient()
+	return ctx, stop, client.OpenWorkspace(wid)
+}
+
+// newClient returns a client and a context that is cancelled on interrupt
+func newClient() (context.Context, context.CancelFunc, *pshdlApi.Client) {
+	client, err := pshdlApi.NewClient(pshdlApi.WithRetryPolicy(pshdlApi.DefaultRetryPolicy))
+	check(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	return ctx, stop, client
+}
+
+func push(c *cli.Context) {
+	ctx, stop, ws := openWorkspace(c)
+	defer stop()
+
+	report, err := ws.UploadDir(ctx, c.String("dir"), pshdlApi.UploadDirOptions{
+		DryRun: c.Bool("dry-run"),
+	})
+	check(err)
+
+	verb := "uploaded"
+	if c.Bool("dry-run") {
+		verb = "would upload"
+	}
+	for _, name := range report.Uploaded {
+		log.Printf("%s %s\n", verb, name)
+	}
+
+	if c.Bool("verbose") {
+		for _, name := range report.Ignored {
+			log.Printf("ignored %s\n", name)
+		}
+		for _, name := range report.Unmatched {
+			log.Printf("skipped %s\n", name)
+		}
+	}
+
+	log.Printf("%d files %s to %s, %d ignored, %d skipped\n",
+		len(report.Uploaded), verb, ws.ID(), len(report.Ignored), len(report.Unmatched))
+}
+
+func diff(c *cli.Context) {
+	ctx, stop, ws := openWorkspace(c)
+	defer stop()
+
+	diffs, err := ws.Diff(ctx, c.String("dir"), nil)
+	check(err)
+
+	changed := 0
+	for _, d := range diffs {
+		if d.Status == pshdlApi.DiffUnchanged {
+			if c.Bool("verbose") {
+				fmt.Printf("%-9s %s\n", d.Status, d.Path)
+			}
+			continue
+		}
+
+		changed++
+		fmt.Printf("%-9s %s\n", d.Status, d.Path)
+		if d.Diff != "" && !c.Bool("name-only") {
+			fmt.Print(d.Diff)
+		}
+	}
+
+	log.Printf("%d of %d files differ from %s\n", changed, len(diffs), ws.ID())
+}
+
+func compile(c *cli.Context) {
+	var targets []pshdlApi.CompileTarget
+	for _, name := range c.Args() {
+		t, err := pshdlApi.ParseCompileTarget(name)
+		check(err)
+		targets = append(targets, t)
+	}
+
+	ctx, stop, ws := openWorkspace(c)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(ctx, c.Duration("timeout"))
+	defer cancel()
+
+	res, err := ws.CompileAndWait(ctx, targets...)
+	if errors.Is(err, pshdlApi.ErrValidationFailed) {
+		log.Println(summary(res.Validation))
+	}
+	check(err)
+
+	log.Printf("%s, %d files generated, %d compiler problems\n", summary(res.Validation), len(res.Records), len(res.Problems))
+	check(ws.DownloadRecords(ctx, res.Records, pshdlApi.DownloadOptions{
+		Dir: c.String("dir"),
+		Progress: func(p pshdlApi.Progress) {
+			if p.Err == nil {
+				log.Printf("* %s\n", p.Record.RelPath)
+			}
+		},
+	}))
+}
+
+// problems exits with status 1 if there are errors, so scripts can use it as a check
+func problems(c *cli.Context) {
+	min, err := pshdlApi.ParseSeverity(c.String("min"))
+	check(err)
+
+	ctx, stop, ws := openWorkspace(c)
+	defer stop()
+
+	res, err := ws.Validate(ctx)
+	check(err)
+
+	check(problemfmt.Write(os.Stdout, res.Workspace.Files, problemfmt.Options{
+		Explanation: c.Bool("explain"),
+		Solutions:   c.Bool("solutions"),
+		MinSeverity: min,
+	}))
+
+	if res.HasErrors() {
+		stop()
+		os.Exit(1)
+	}
+}
+
+func clone(c *cli.Context) {
+	src := c.Args().First()
+	if src == "" {
+		log.Fatalln("please supply the workspace to clone")
+	}
+
+	ctx, stop, client := newClient()
+	defer stop()
+
+	ws, report, err := client.CloneWorkspace(ctx, src, pshdlApi.CreateOptions{})
+	check(err)
+
+	for _, name := range report.Skipped {
+		log.Printf("skipped %s\n", name)
+	}
+	log.Printf("cloned %s into %s: %d files, %d bytes, %s\n",
+		src, ws.ID(), len(report.Copied), report.Bytes, summary(report.Validation))
+
+	if c.Bool("wid") {
+		check(ioutil.WriteFile(widFname, []byte(ws.ID()), 0644))
+	}
+}
+
+// archiveFormat returns the format given with --format or by the extension of fname
+func archiveFormat(c *cli.Context, fname string) pshdlApi.ArchiveFormat {
+	name := c.String("format")
+	if name == "" {
+		name = fname
+	}
+
+	format, err := pshdlApi.ParseArchiveFormat(name)
+	check(err)
+	return format
+}
+
+func export(c *cli.Context) {
+	out := c.String("out")
+	if out == "" {
+		log.Fatalln("please supply an archive with --out")
+	}
+	format := archiveFormat(c, out)
+
+	ctx, stop, ws := openWorkspace(c)
+	defer stop()
+
+	f, err := os.Create(out)
+	check(err)
+
+	err = ws.ExportArchive(ctx, f, format, pshdlApi.ExportOptions{
+		Generated: c.Bool("generated"),
+		Manifest:  c.Bool("manifest"),
+	})
+	if err != nil {
+		f.Close()
+		os.Remove(out)
+		log.Fatalln(err)
+	}
+	check(f.Close())
+
+	log.Printf("exported %s to %s\n", ws.ID(), out)
+}
+
+func importArchive(c *cli.Context) {
+	in := c.String("in")
+	if in == "" {
+		log.Fatalln("please supply an archive with --in")
+	}
+	format := archiveFormat(c, in)
+
+	f, err := os.Open(in)
+	check(err)
+	defer f.Close()
+
+	var (
+		res *pshdlApi.ValidationResult
+		id  string
+	)
+	if c.Bool("new") {
+		ctx, stop, client := newClient()
+		defer stop()
+
+		var ws *pshdlApi.WorkspaceHandle
+		ws, res, err = client.ImportArchive(ctx, f, format, pshdlApi.CreateOptions{})
+		check(err)
+		id = ws.ID()
+	} else {
+		ctx, stop, ws := openWorkspace(c)
+		defer stop()
+
+		res, err = ws.ImportArchive(ctx, f, format)
+		check(err)
+		id = ws.ID()
+	}
+
+	log.Printf("imported %s into %s, %s\n", in, id, summary(res))
+}
+
+// summary describes the outcome of a validation in a few words
+func summary(res *pshdlApi.ValidationResult) string {
+	if !res.Workspace.Validated {
+		return "not validated"
+	}
+	if res.Clean() {
+		return "validated without problems"
+	}
+	return fmt.Sprintf("validated with %d errors, %d warnings, %d infos",
+		res.ErrorCount, res.WarningCount, res.InfoCount)
+}
+
+func check(err error) {
+	if err != nil {
+		log.Fatalln(err)
+	}
+}
@@ -1,13 +1,18 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strings"
 
 	"github.com/cryptix/goPshdlRest/api"
+	"github.com/cryptix/goPshdlRest/api/recorder"
 )
 
 const widFname = ".wid"
@@ -15,6 +20,9 @@ const widFname = ".wid"
 var (
 	streamVHDL = flag.Bool("vhdl", false, "download generated vhdl")
 	streamCSim = flag.Bool("csim", false, "download generated C Simulation code")
+
+	cassette = flag.String("cassette", "", "replay the API session stored in this file")
+	record   = flag.Bool("record", false, "record the API session into -cassette instead of replaying it")
 )
 
 func main() {
@@ -27,6 +35,9 @@ func main() {
 		Use these flags to download the wanted files.
 		-vhdl 	For generated VHDL
 		-csim 	For generated C Simulation
+
+		-cassette file	Replay a recorded API session
+		-record 	Record the API session into the cassette
 		`)
 	}
 
@@ -52,17 +63,73 @@ func main() {
 		log.Fatalf("Error: %s\n", err)
 	}
 
-	// TODO: pshdlApi.OpenWorkspace()
-	client := pshdlApi.NewClientWithID(nil, string(wid[:16]))
+	var (
+		rec        *recorder.Recorder
+		httpClient *http.Client
+	)
+	if *cassette != "" {
+		mode := recorder.ModeReplay
+		if *record {
+			mode = recorder.ModeRecord
+		}
+
+		rec, err = recorder.New(*cassette, mode)
+		if err != nil {
+			log.Fatalf("Error: %s\n", err)
+		}
 
-	evChan, err := client.Streaming.OpenEventStream()
+		log.Printf("Cassette %s (%s)\n", *cassette, mode)
+		httpClient = rec.Client()
+	}
+
+	// stop streaming on ^C so the cassette gets finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err = stream(ctx, httpClient, string(wid[:16]))
+	stop()
+
+	// the recorder is stopped before every exit, so the cassette gets written
+	if rec != nil {
+		if serr := rec.Stop(); serr != nil {
+			log.Println("Error: saving cassette:", serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}
 	if err != nil {
 		log.Fatalf("Error: %s\n", err)
 	}
+}
+
+// stream downloads the generated code the event stream of the workspace announces until ctx is done
+func stream(ctx context.Context, httpClient *http.Client, wid string) error {
+	client, err := pshdlApi.NewClient(
+		pshdlApi.WithHTTPClient(httpClient),
+		pshdlApi.WithRetryPolicy(pshdlApi.DefaultRetryPolicy),
+	)
+	if err != nil {
+		return err
+	}
+	ws := client.OpenWorkspace(wid)
+
+	evChan, err := ws.OpenEventStream(ctx)
+	if err != nil {
+		return err
+	}
 	log.Println("EventStream open. PID:", os.Getpid())
 
-	if err = client.Streaming.SendClientConnected(); err != nil {
-		log.Fatalf("Error: %s\n", err)
+	if err = ws.SendClientConnected(ctx); err != nil {
+		return err
+	}
+
+	dlOpts := pshdlApi.DownloadOptions{
+		Progress: func(p pshdlApi.Progress) {
+			if p.Err != nil {
+				log.Printf("[!] %s\n", p.Err)
+				return
+			}
+			log.Printf("[*] %s (%d left)\n", p.Record.RelPath, p.FilesLeft)
+		},
 	}
 
 	for ev := range evChan {
@@ -77,21 +144,20 @@ func main() {
 			}
 
 		case subj == "P:COMPILER:VHDL" && *streamVHDL:
-			err = client.Workspace.DownloadRecords(ev.GetFiles())
+			err = ws.DownloadRecords(ctx, ev.GetFiles(), dlOpts)
 			if err != nil {
-				log.Fatalf("Workspace.DownloadRecords() Error:. %s", err)
-				break
+				return fmt.Errorf("Workspace.DownloadRecords(): %w", err)
 			}
 			log.Println("[*] VHDL Download finished..")
 
 		case subj == "P:COMPILER:C" && *streamCSim:
-			err = client.Workspace.DownloadRecords(ev.GetFiles())
+			err = ws.DownloadRecords(ctx, ev.GetFiles(), dlOpts)
 			if err != nil {
-				log.Fatalf("Could not load all files. %s", err)
-				break
+				return fmt.Errorf("could not load all files: %w", err)
 			}
 			log.Println("[*] CSim Download finished..")
 		}
 
 	}
+	return nil
 }
@@ -1,10 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/cryptix/goPshdlRest/api"
@@ -25,11 +24,12 @@ func main() {
 	app.Name = appName
 	app.Usage = "request simulation code and download it"
 	app.Flags = []cli.Flag{
-		cli.StringFlag{Name: "lang,l", Value: "c", Usage: "In which language"},
+		cli.StringFlag{Name: "lang,l", Value: "c", Usage: "In which language: psex, java, c, go, dart or javascript"},
 		cli.StringFlag{Name: "workspace,w", Value: "", Usage: "The workspace to use"},
 		cli.StringFlag{Name: "module,m", Value: "", Usage: "The module that should be requested"},
 		cli.BoolFlag{Name: "base,b", Usage: "strip the relPath to it's base"},
 		cli.StringFlag{Name: "dir,d", Value: "", Usage: "The dir where downloaded code should be stored"},
+		cli.IntFlag{Name: "jobs,j", Value: pshdlApi.DefaultConcurrency, Usage: "How many files to download in parallel"},
 	}
 	app.Action = run
 
@@ -50,39 +50,29 @@ func run(c *cli.Context) {
 		os.Exit(1)
 	}
 
-	apiClient = pshdlApi.NewClientWithID(nil, wid)
-
-	// TODO push this into the api
-	var simLang pshdlApi.SimCodeType
-	switch c.String("lang") {
-	case "c":
-		simLang = pshdlApi.SimC
-	case "go":
-		simLang = pshdlApi.SimGo
-	default:
-		log.Println("Unknown SimCodeType")
-		os.Exit(1)
-	}
-
-	uris, err := apiClient.Compiler.RequestSimCode(simLang, moduleName)
+	ctx := context.Background()
+	var err error
+	apiClient, err = pshdlApi.NewClient(
+		pshdlApi.WithWorkspaceID(wid),
+		pshdlApi.WithRetryPolicy(pshdlApi.DefaultRetryPolicy),
+	)
 	check(err)
 
-	// construct []Record
-	recs := make([]pshdlApi.Record, len(uris))
-	for i, uri := range uris {
-		uriParts := strings.Split(uri, "/")
-		relPath := strings.Replace(uriParts[len(uriParts)-1], ":", "/", -1)
-		if c.Bool("base") {
-			relPath = filepath.Base(relPath)
-		}
-
-		recs[i] = pshdlApi.Record{
-			FileURI: uri,
-			RelPath: relPath,
-		}
-	}
+	simLang, err := pshdlApi.ParseSimCodeType(c.String("lang"))
+	check(err)
 
-	check(apiClient.Workspace.DownloadRecords(recs))
+	_, err = apiClient.Compiler.FetchSimCode(ctx, simLang, moduleName, c.String("dir"), pshdlApi.SimCodeOptions{
+		Flatten: c.Bool("base"),
+		Download: pshdlApi.DownloadOptions{
+			Concurrency: c.Int("jobs"),
+			Progress: func(p pshdlApi.Progress) {
+				if p.Err == nil {
+					log.Printf("* %s (%d/%d, %d bytes)", p.Record.RelPath, p.FilesDone, p.FilesDone+p.FilesLeft, p.Bytes)
+				}
+			},
+		},
+	})
+	check(err)
 	log.Println("Fetched all files")
 
 }
@@ -60,11 +60,11 @@ func handler(rw http.ResponseWriter, req *http.Request) {
 }
 
 func validateHandler(rw http.ResponseWriter, req *http.Request) {
-	var err error
-	workspace, err = apiClient.Compiler.Validate()
+	res, err := apiClient.Compiler.Validate(req.Context())
 	if err != nil {
 		http.Error(rw, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	workspace = res.Workspace
 	http.Redirect(rw, req, "/", http.StatusFound)
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -59,11 +60,16 @@ func run(c *cli.Context) {
 	case <-time.After(time.Second * 1):
 	}
 
-	apiClient = pshdlApi.NewClientWithID(nil, wid)
+	apiClient, err = pshdlApi.NewClient(
+		pshdlApi.WithWorkspaceID(wid),
+		pshdlApi.WithRetryPolicy(pshdlApi.DefaultRetryPolicy),
+		pshdlApi.WithCache(pshdlApi.NewMemoryCache()),
+	)
+	check(err)
 
 	updateWorkspace()
 
-	evChan, err := apiClient.Streaming.OpenEventStream()
+	evChan, err := apiClient.Streaming.OpenEventStream(context.Background())
 	check(err)
 	log.Println("EventStream open")
 
@@ -120,7 +126,7 @@ func run(c *cli.Context) {
 func updateWorkspace() {
 	var err error
 	start := time.Now()
-	workspace, _, err = apiClient.Workspace.GetInfo()
+	workspace, _, err = apiClient.Workspace.GetInfo(context.Background())
 	if err != nil {
 		log.Printf("updateWorkspace Failed: %s (%v)\n", err, time.Since(start))
 		return
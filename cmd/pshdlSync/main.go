@@ -1,15 +1,19 @@
 package main
 
 import (
+	"context"
+	"io/fs"
 	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"runtime"
 	"strings"
 
 	"github.com/codegangsta/cli"
 	"github.com/cryptix/goPshdlRest/api"
+	"github.com/cryptix/goPshdlRest/api/recorder"
 	"github.com/visionmedia/go-debug"
 	"gopkg.in/fsnotify.v1"
 )
@@ -27,69 +31,220 @@ func main() {
 	app.Usage = "sync a remote PSHDL workspace with your filesystem"
 	app.Flags = []cli.Flag{
 		cli.StringFlag{Name: "workspace,w", Usage: "specifiy the workspace to connect to"},
+		cli.StringFlag{Name: "cassette", Usage: "replay the API session stored in this file"},
+		cli.BoolFlag{Name: "record", Usage: "record the API session into --cassette instead of replaying it"},
+		cli.StringFlag{Name: "name", Usage: "name to register a new workspace with"},
+		cli.StringFlag{Name: "email", Usage: "e-mail to register a new workspace with"},
+		cli.IntFlag{Name: "jobs,j", Value: pshdlApi.DefaultConcurrency, Usage: "how many files to download in parallel"},
+		cli.StringFlag{Name: "cache-dir", Usage: "cache downloads in this directory, ignored with --cassette"},
 	}
 	app.Action = run
 
 	app.Run(os.Args)
 }
 
+// run stops the recorder before every exit, so the cassette gets written
 func run(c *cli.Context) {
 	var (
-		err    error
-		client *pshdlApi.Client
-		wp     *pshdlApi.Workspace
+		rec        *recorder.Recorder
+		httpClient *http.Client
 	)
+	if cassette := c.String("cassette"); cassette != "" {
+		mode := recorder.ModeReplay
+		if c.Bool("record") {
+			mode = recorder.ModeRecord
+		}
+
+		var err error
+		rec, err = recorder.New(cassette, mode)
+		if err != nil {
+			log.Fatal("Error:", err)
+		}
+
+		log.Printf("Cassette %s (%s)\n", cassette, mode)
+		httpClient = rec.Client()
+	}
+
+	// stop watching on ^C so the cassette gets finished
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	err := syncWorkspace(ctx, c, httpClient)
+	stop()
+
+	if rec != nil {
+		if serr := rec.Stop(); serr != nil {
+			log.Println("Error: saving cassette:", serr)
+			if err == nil {
+				err = serr
+			}
+		}
+	}
+	if err != nil {
+		log.Fatal("Error:", err)
+	}
+}
+
+// syncWorkspace downloads the workspace and uploads local changes until ctx is done
+func syncWorkspace(ctx context.Context, c *cli.Context, httpClient *http.Client) error {
+	var (
+		err error
+		ws  *pshdlApi.WorkspaceHandle
+		wp  *pshdlApi.Workspace
+	)
+
+	opts := []pshdlApi.Option{
+		pshdlApi.WithHTTPClient(httpClient),
+		pshdlApi.WithRetryPolicy(pshdlApi.DefaultRetryPolicy),
+	}
+
+	// a cassette has to see every download, so it can replay them on its own
+	cacheDir := c.String("cache-dir")
+	if cacheDir != "" && httpClient != nil {
+		log.Println("Cassette active, not using the cache in", cacheDir)
+		cacheDir = ""
+	}
+	if cacheDir != "" {
+		cache, err := pshdlApi.NewDiskCache(cacheDir)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pshdlApi.WithCache(cache))
+	}
+
+	client, err := pshdlApi.NewClient(opts...)
+	if err != nil {
+		return err
+	}
 
 	widStat, widStatErr := os.Stat(widFname)
 
 	if os.IsNotExist(widStatErr) {
-		// TODO: pshdlApi.CreateWorkspace()
-		client = pshdlApi.NewClient(nil)
-		wp, _, err = client.Workspace.Create()
-		check(err)
+		ws, wp, err = client.CreateWorkspace(ctx, pshdlApi.CreateOptions{
+			Name:  c.String("name"),
+			Email: c.String("email"),
+		})
+		if err != nil {
+			return err
+		}
 		log.Println("Workspace Created:", wp.ID)
 
-		err = ioutil.WriteFile(widFname, []byte(wp.ID), os.ModePerm-7)
-		check(err)
+		if err = ioutil.WriteFile(widFname, []byte(wp.ID), os.ModePerm-7); err != nil {
+			return err
+		}
 	}
 
 	if widStat != nil && widStat.Mode().IsRegular() {
 		wid, err := ioutil.ReadFile(widFname)
-		check(err)
+		if err != nil {
+			return err
+		}
 
-		// TODO: pshdlApi.OpenWorkspace()
-		client = pshdlApi.NewClientWithID(nil, string(wid[:16]))
+		ws = client.OpenWorkspace(string(wid[:16]))
 	}
 
-	wp, _, err = client.Workspace.GetInfo()
-	check(err)
+	wp, _, err = ws.GetInfo(ctx)
+	if err != nil {
+		return err
+	}
 	log.Printf("Workspace Opened:%s - PID:%d", wp.ID, os.Getpid())
 	log.Println("Files:")
-	recs := make([]pshdlApi.Record, len(wp.Files))
-	for i, f := range wp.Files {
+	var recs []pshdlApi.Record
+	for _, f := range wp.Files {
 		log.Printf("* %s\nInfos:%s\n", f.Record.RelPath, f.ModuleInfos)
-		recs[i] = f.Record
+
+		// skip files that are already there
+		if local, err := pshdlApi.HashFile(f.Record.RelPath); err == nil && pshdlApi.SameHash(local, f.Record.Hash) {
+			dbg("unchanged: %s", f.Record.RelPath)
+			continue
+		}
+		recs = append(recs, f.Record)
 	}
 
-	// todo check if files allready there
-	err = client.Workspace.DownloadRecords(recs)
-	check(err)
+	// the others are taken from the cache if possible
+	err = ws.DownloadRecords(ctx, recs, pshdlApi.DownloadOptions{
+		Concurrency: c.Int("jobs"),
+		Progress: func(p pshdlApi.Progress) {
+			dbg("downloaded %s - %d bytes, %d left", p.Record.RelPath, p.Bytes, p.FilesLeft)
+		},
+	})
+	if err != nil {
+		return err
+	}
 	log.Println("Download of PSHDL-Code complete.")
 
 	//todo push containing files
 	log.Println("Starting to watch..")
 
+	cwd, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+
+	ign, err := pshdlApi.LoadIgnore(cwd)
+	if err != nil {
+		return err
+	}
+
 	watcher, err := fsnotify.NewWatcher()
-	check(err)
+	if err != nil {
+		return err
+	}
 	defer watcher.Close()
 
-	done := make(chan struct{})
+	// relPath returns the workspace name of a local path and if it isn't ignored
+	relPath := func(fpath string, isDir bool) (string, bool) {
+		rel, err := filepath.Rel(cwd, fpath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			return "", false
+		}
+		rel = filepath.ToSlash(rel)
+		return rel, rel == "." || !ign.Match(rel, isDir)
+	}
+
+	// watch adds dir and all directories below it that aren't ignored
+	watch := func(dir string) error {
+		return filepath.WalkDir(dir, func(fpath string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return err
+			}
+			if _, ok := relPath(fpath, true); !ok {
+				return filepath.SkipDir
+			}
+			dbg("watching %s", fpath)
+			return watcher.Add(fpath)
+		})
+	}
+
+	// upload sends a changed file and validates the workspace
+	upload := func(fpath, fname string) error {
+		dbg("write to %s", fname)
+		file, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		if err := ws.UploadFile(ctx, fname, file); err != nil {
+			return err
+		}
+		dbg("uploaded %s", fname)
+
+		res, err := ws.Validate(ctx)
+		if err != nil {
+			return err
+		}
+		dbg("validated %s", ws.ID())
+
+		log.Printf("Uploaded %s and Validated: %d errors, %d warnings\n", fname, res.ErrorCount, res.WarningCount)
+		return nil
+	}
+
+	// failed gets the first error of the watcher goroutines
+	failed := make(chan error, 2)
 
 	// Process events
 	go func() {
-		for err := range watcher.Errors {
-			check(err)
-			close(done)
+		if err, ok := <-watcher.Errors; ok {
+			failed <- err
 		}
 	}()
 
@@ -97,51 +252,46 @@ func run(c *cli.Context) {
 		for ev := range watcher.Events {
 			dbg("watcher event: %s", ev)
 
-			if strings.HasSuffix(ev.Name, ".pshdl") {
-				switch {
-				case ev.Op&fsnotify.Create == fsnotify.Create:
-					fallthrough
-				case ev.Op&fsnotify.Write == fsnotify.Write:
-					fname := filepath.Base(ev.Name)
-					dbg("write to %s", fname)
-					file, err := os.Open(ev.Name)
-					check(err)
-
-					err = client.Workspace.UploadFile(filepath.Base(ev.Name), file)
-					check(err)
-					file.Close()
-					dbg("uploaded %s", fname)
-
-					_, err = client.Compiler.Validate()
-					check(err)
-					dbg("validated %s", client.Workspace.ID)
-
-					log.Printf("Uploaded %s and Validated\n", fname)
-
-				case ev.Op&fsnotify.Remove == fsnotify.Remove:
-					log.Println(ev.Name, "deleted, skipping...")
-					// client.Workspace.Delete(ev.Name)
+			if ev.Op&fsnotify.Create == fsnotify.Create {
+				if fi, err := os.Stat(ev.Name); err == nil && fi.IsDir() {
+					if err := watch(ev.Name); err != nil {
+						failed <- err
+						return
+					}
+					continue
 				}
 			}
-		}
-		close(done)
-	}()
 
-	cwd, err := os.Getwd()
-	check(err)
+			fname, ok := relPath(ev.Name, false)
+			if !ok || !strings.HasSuffix(fname, ".pshdl") {
+				continue
+			}
 
-	err = watcher.Add(cwd)
-	check(err)
+			switch {
+			case ev.Op&fsnotify.Create == fsnotify.Create:
+				fallthrough
+			case ev.Op&fsnotify.Write == fsnotify.Write:
+				if err := upload(ev.Name, fname); err != nil {
+					failed <- err
+					return
+				}
 
-	<-done
+			case ev.Op&fsnotify.Remove == fsnotify.Remove:
+				log.Println(fname, "deleted, skipping...")
+				// ws.Delete(ctx, fname)
+			}
+		}
+	}()
 
-	os.Exit(0)
-}
+	if err = watch(cwd); err != nil {
+		return err
+	}
 
-func check(err error) {
-	if err != nil {
-		_, file, line, _ := runtime.Caller(1)
-		log.Printf("Fatal from <%s:%d>\n", file, line)
-		log.Fatal("Error:", err)
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+		log.Println("Stopped watching.")
+		return nil
 	}
 }